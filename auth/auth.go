@@ -0,0 +1,116 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+// Package auth obtains access tokens for the MCP server
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Logger is an alias for log.Logger
+type Logger = *log.Logger
+
+// TokenSource supplies the bearer token sent in the Authorization header of every request
+type TokenSource interface {
+	// Token returns the current access token, refreshing it first if it has expired
+	// An empty token means that none is available yet; the server's 401 response will
+	// then lead to a call to Unauthorized.
+	Token(ctx context.Context) (string, error)
+
+	// Unauthorized is called when the server rejects a request with 401 (or 403 with
+	// error="insufficient_scope"). token is the access token that was sent, challenge the
+	// WWW-Authenticate header. When it returns nil a new token is available and the request
+	// may be retried.
+	Unauthorized(ctx context.Context, token string, challenge string) error
+}
+
+// parseChallenge returns the parameters of the Bearer challenge in a WWW-Authenticate header
+// See RFC 9110 section 11.6.1 and RFC 6750 section 3. Parameter names are lowercased.
+// nil is returned if there is no Bearer challenge.
+func parseChallenge(header string) map[string]string {
+	var params map[string]string
+	s := header
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+
+		// Read a token: either an auth scheme or a parameter name
+		end := strings.IndexAny(s, " \t,=")
+		if end < 0 {
+			end = len(s)
+		}
+		token := s[:end]
+		s = strings.TrimLeft(s[end:], " \t")
+
+		if !strings.HasPrefix(s, "=") {
+			// A new challenge starts; stop if the Bearer challenge is complete
+			if params != nil {
+				return params
+			}
+			if strings.EqualFold(token, "Bearer") {
+				params = make(map[string]string)
+			}
+			continue
+		}
+
+		// Parameter value, either a token or a quoted string
+		s = strings.TrimLeft(s[1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			s = s[min(i+1, len(s)):]
+		} else {
+			end = strings.IndexAny(s, " \t,")
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+
+		if params != nil {
+			params[strings.ToLower(token)] = value
+		}
+	}
+}
+
+// isLoopback returns true if the URL refers to the local machine
+func isLoopback(u *url.URL) bool {
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkURL verifies that an authorization server URL uses HTTPS, as required by OAuth 2.1
+// Plain HTTP is accepted for loopback addresses so that a local authorization server can be used.
+func checkURL(name string, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s '%s': %s", name, raw, err.Error())
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLoopback(u)) {
+		return nil, fmt.Errorf("The %s must use https: %s", name, raw)
+	}
+	return u, nil
+}
@@ -0,0 +1,52 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		header string
+		want   map[string]string
+	}{
+		{"", nil},
+		{`Basic realm="mcp"`, nil},
+		{"Bearer", map[string]string{}},
+		{
+			`Bearer resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource"`,
+			map[string]string{"resource_metadata": "https://mcp.example.com/.well-known/oauth-protected-resource"},
+		},
+		{
+			`Bearer Error="insufficient_scope", scope="files:read files:write"`,
+			map[string]string{"error": "insufficient_scope", "scope": "files:read files:write"},
+		},
+		{
+			`Bearer realm=mcp,error=invalid_token`,
+			map[string]string{"realm": "mcp", "error": "invalid_token"},
+		},
+		{
+			`Bearer error_description="say \"hello\", then go"`,
+			map[string]string{"error_description": `say "hello", then go`},
+		},
+		{
+			`Basic realm="basic", Bearer realm="bearer", error="invalid_token", DPoP algs="ES256"`,
+			map[string]string{"realm": "bearer", "error": "invalid_token"},
+		},
+		{
+			`bearer scope="mcp"`,
+			map[string]string{"scope": "mcp"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := parseChallenge(test.header); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseChallenge(%q) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
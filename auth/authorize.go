@@ -0,0 +1,174 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// callbackPath is the path of the redirect URI on the loopback listener
+const callbackPath = "/callback"
+
+// authorizeTimeout is how long the user has to complete authorization in the browser
+const authorizeTimeout = 5 * time.Minute
+
+// callbackResult is what the authorization server sent to the redirect URI
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// randomString returns a URL-safe random string for PKCE verifiers and state values
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pkceChallenge returns the S256 code challenge for a verifier (RFC 7636)
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// listenLoopback opens the listener for the redirect URI
+// A previously registered redirect URI is reused if its port is free, so that the
+// client registration remains valid. port 0 picks any free port.
+func listenLoopback(port int, previous string) (net.Listener, string, error) {
+	if previous != "" {
+		if u, err := url.Parse(previous); err == nil && u.Hostname() == "127.0.0.1" {
+			if l, err := net.Listen("tcp", u.Host); err == nil {
+				return l, previous, nil
+			}
+		}
+	}
+
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to open OAuth redirect listener: %s", err.Error())
+	}
+	return l, fmt.Sprintf("http://%s%s", l.Addr().String(), callbackPath), nil
+}
+
+// authorizeInBrowser runs the authorization code flow with PKCE (OAuth 2.1 section 4.1)
+// The user is sent to the authorization endpoint in a browser, and the loopback listener
+// receives the authorization code once they have approved access.
+func (o *OAuth) authorizeInBrowser(ctx context.Context, listener net.Listener, metadata *serverMetadata, client *registration, scope string, resource string) (string, string, error) {
+	verifier := randomString()
+	state := randomString()
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {client.RedirectURI},
+		"code_challenge":        {pkceChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+		"state":                 {state},
+		"resource":              {resource},
+	}
+	if scope != "" {
+		query.Set("scope", scope)
+	}
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", err
+	}
+	for key, values := range authURL.Query() {
+		if !query.Has(key) {
+			query[key] = values
+		}
+	}
+	authURL.RawQuery = query.Encode()
+
+	results := make(chan callbackResult, 1)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != callbackPath {
+				http.NotFound(w, req)
+				return
+			}
+			params := req.URL.Query()
+			if params.Get("state") != state {
+				http.Error(w, "Invalid state parameter", http.StatusBadRequest)
+				return
+			}
+
+			var result callbackResult
+			if errCode := params.Get("error"); errCode != "" {
+				result.err = fmt.Errorf("Authorization denied: %s %s", errCode, params.Get("error_description"))
+			} else if result.code = params.Get("code"); result.code == "" {
+				result.err = fmt.Errorf("Authorization response contains no code")
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if result.err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "<html><body><h1>Authorization failed</h1><p>%s</p></body></html>", html.EscapeString(result.err.Error()))
+			} else {
+				fmt.Fprint(w, "<html><body><h1>Authorization complete</h1><p>You may close this window.</p></body></html>")
+			}
+
+			select {
+			case results <- result:
+			default:
+			}
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	o.logger.Printf("OAuth: authorization required, open %s", authURL.String())
+	fmt.Fprintf(os.Stderr, "MCPRelay: to authorize access to %s, open this URL in your browser:\n%s\n", o.serverURL, authURL.String())
+	if !o.config.NoBrowser {
+		if err = openURL(authURL.String()); err != nil {
+			o.logger.Printf("OAuth: failed to open browser: %s", err.Error())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authorizeTimeout)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+		return "", "", fmt.Errorf("Authorization was not completed within %s", authorizeTimeout)
+	case result := <-results:
+		return result.code, verifier, result.err
+	}
+}
+
+// openURL opens a URL in the user's browser
+func openURL(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		_ = cmd.Wait()
+	}()
+	return nil
+}
@@ -0,0 +1,111 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// registration is the client registered with an authorization server
+type registration struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	AuthMethod   string `json:"token_endpoint_auth_method,omitempty"`
+	RedirectURI  string `json:"redirect_uri,omitempty"`
+}
+
+// cacheEntry is what is stored on disk for each MCP server
+type cacheEntry struct {
+	Resource      string        `json:"resource"`
+	Issuer        string        `json:"issuer"`
+	TokenEndpoint string        `json:"token_endpoint"`
+	Client        *registration `json:"client,omitempty"`
+	Token         *token        `json:"token,omitempty"`
+}
+
+// tokenCache stores tokens and client registrations in a directory that only the user can read
+// There is one file per MCP server, named after a hash of its URL.
+type tokenCache struct {
+	dir string // empty to disable caching
+}
+
+// defaultCacheDir returns the directory used if none is configured
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mcprelay", "oauth")
+}
+
+func (c *tokenCache) path(resource string) string {
+	sum := sha256.Sum256([]byte(resource))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// load returns the cached entry for the resource, or nil if there is none
+func (c *tokenCache) load(resource string) (*cacheEntry, error) {
+	if c.dir == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(c.path(resource))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("Invalid token cache file %s: %s", c.path(resource), err.Error())
+	}
+	if entry.Resource != resource {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// save writes the entry atomically with permissions 0600
+func (c *tokenCache) save(entry *cacheEntry) error {
+	if c.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp uses 0600, but be explicit as the file holds credentials
+	if err = tmp.Chmod(0o600); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(entry.Resource))
+}
@@ -0,0 +1,154 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ClientCredentialsConfig configures the client credentials grant
+// The client authenticates with either a secret or a private key.
+type ClientCredentialsConfig struct {
+	TokenURL       string   // token endpoint of the authorization server
+	ClientID       string   // client ID
+	ClientSecret   string   // client secret, for client_secret_basic authentication
+	PrivateKeyFile string   // PEM private key, for private_key_jwt authentication
+	KeyID          string   // key ID sent in the client assertion header, optional
+	Scopes         []string // scopes to request
+	Audience       string   // audience parameter for authorization servers that require one
+}
+
+// ClientCredentials obtains tokens with the OAuth client credentials grant (OAuth 2.1 section 4.2)
+// This needs no user interaction, so it is suitable for service accounts and CI. Tokens are
+// kept in memory and requested again shortly before they expire.
+type ClientCredentials struct {
+	resource string // canonical URI of the MCP server (RFC 8707)
+	config   ClientCredentialsConfig
+	key      *signingKey // nil when using a client secret
+	client   *http.Client
+	logger   Logger
+
+	mutex sync.Mutex
+	token *token
+}
+
+// NewClientCredentials creates a token source for the MCP server at serverURL
+// client is used for all requests to the token endpoint.
+func NewClientCredentials(serverURL string, cfg ClientCredentialsConfig, client *http.Client, logger Logger) (*ClientCredentials, error) {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if cfg.TokenURL == "" {
+		return nil, errors.New("The client credentials grant requires a token URL")
+	}
+	if _, err := checkURL("token endpoint", cfg.TokenURL); err != nil {
+		return nil, err
+	}
+	if cfg.ClientID == "" {
+		return nil, errors.New("The client credentials grant requires a client ID")
+	}
+	if (cfg.ClientSecret == "") == (cfg.PrivateKeyFile == "") {
+		return nil, errors.New("The client credentials grant requires either a client secret or a private key")
+	}
+
+	c := &ClientCredentials{
+		resource: canonicalResource(serverURL),
+		config:   cfg,
+		client:   client,
+		logger:   logger,
+	}
+	if cfg.PrivateKeyFile != "" {
+		key, err := loadSigningKey(cfg.PrivateKeyFile, cfg.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		c.key = key
+	}
+	return c, nil
+}
+
+// Token returns the current access token, requesting a new one if it is about to expire
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.token.valid() {
+		if err := c.request(ctx); err != nil {
+			return "", err
+		}
+	}
+	return c.token.AccessToken, nil
+}
+
+// Unauthorized requests a new token after the server rejected sent
+func (c *ClientCredentials) Unauthorized(ctx context.Context, sent string, _ string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another request may have obtained a new token in the meantime
+	if c.token.valid() && c.token.AccessToken != sent {
+		return nil
+	}
+	c.token = nil
+	return c.request(ctx)
+}
+
+// request obtains a new token from the token endpoint
+// The caller must hold the mutex.
+func (c *ClientCredentials) request(ctx context.Context) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.config.Scopes, " "))
+	}
+
+	// Authorization servers that use audience generally reject the resource parameter
+	if c.config.Audience != "" {
+		form.Set("audience", c.config.Audience)
+	} else {
+		form.Set("resource", c.resource)
+	}
+
+	t, err := requestToken(ctx, c.client, c.config.TokenURL, form, c.authenticate, nil)
+	if err != nil {
+		c.logger.Printf("OAuth: client credentials token request failed: %s", err.Error())
+		return err
+	}
+
+	c.logger.Printf("OAuth: obtained access token for client %s", c.config.ClientID)
+	c.token = t
+	return nil
+}
+
+// authenticate adds the client secret or a signed client assertion to a token request
+func (c *ClientCredentials) authenticate(req *http.Request, form url.Values) error {
+	if c.key == nil {
+		return clientAuthentication(&registration{
+			ClientID:     c.config.ClientID,
+			ClientSecret: c.config.ClientSecret,
+			AuthMethod:   "client_secret_basic",
+		})(req, form)
+	}
+
+	assertion, err := c.key.clientAssertion(c.config.ClientID, c.config.TokenURL)
+	if err != nil {
+		return err
+	}
+	form.Set("client_id", c.config.ClientID)
+	form.Set("client_assertion_type", assertionType)
+	form.Set("client_assertion", assertion)
+	return nil
+}
@@ -0,0 +1,177 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commandTimeout limits how long the token command may run, e.g. while the user logs in
+const commandTimeout = 2 * time.Minute
+
+// minCommandLifetime is how long a token is used if the command reports that it has already
+// expired (e.g. because of clock skew), so that the command is not run for every request
+const minCommandLifetime = 30 * time.Second
+
+// commandOutput is the JSON a token command may print instead of a plain token
+// The status object is the ExecCredential format of kubectl credential plugins.
+type commandOutput struct {
+	Token       string    `json:"token"`
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int64     `json:"expires_in"`
+	Expiry      time.Time `json:"expiry"`
+	Status      *struct {
+		Token               string    `json:"token"`
+		ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+// Command obtains tokens from an external program, like a git credential helper
+// The command is run with the shell and prints either the token or a JSON object with
+// the token and its expiry. The token is reused until shortly before it expires, or until
+// the server rejects it.
+type Command struct {
+	command string
+	logger  Logger
+
+	mutex sync.Mutex
+	token *token
+}
+
+// NewCommand creates a token source that runs command
+func NewCommand(command string, logger Logger) *Command {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	return &Command{command: command, logger: logger}
+}
+
+// Token returns the current token, running the command if there is none or it is about to expire
+func (c *Command) Token(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.token.valid() {
+		if err := c.run(ctx); err != nil {
+			return "", err
+		}
+	}
+	return c.token.AccessToken, nil
+}
+
+// Unauthorized runs the command again after the server rejected sent
+func (c *Command) Unauthorized(ctx context.Context, sent string, _ string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another request may have obtained a new token in the meantime
+	if c.token.valid() && c.token.AccessToken != sent {
+		return nil
+	}
+	c.token = nil
+	return c.run(ctx)
+}
+
+// run executes the command and parses its output
+// The caller must hold the mutex.
+func (c *Command) run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.command)
+	}
+
+	// stdin and stdout belong to the MCP client, so the command only gets stderr, where
+	// it can show prompts or login URLs
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		c.logger.Printf("Token command failed: %s", err.Error())
+		return fmt.Errorf("Token command failed: %s", err.Error())
+	}
+
+	t, err := parseCommandOutput(stdout.Bytes())
+	if err != nil {
+		c.logger.Printf("Token command failed: %s", err.Error())
+		return err
+	}
+
+	// The output gives the token's expiry, which is replaced by when to run the command again
+	switch {
+	case t.Expiry.IsZero():
+		c.logger.Println("Token command returned a token")
+	case time.Until(t.Expiry) < minCommandLifetime:
+		c.logger.Printf("Token command returned a token that expires at %s, using it for %s", t.Expiry.Format(time.RFC3339), minCommandLifetime)
+		t.Expiry = time.Now().Add(minCommandLifetime)
+	default:
+		t.Expiry = refreshTime(time.Until(t.Expiry))
+		c.logger.Printf("Token command returned a token, renewing it at %s", t.Expiry.Format(time.RFC3339))
+	}
+	c.token = t
+	return nil
+}
+
+// parseCommandOutput returns the token printed by the command
+// Its Expiry is when the token expires according to the output, zero if not given.
+func parseCommandOutput(output []byte) (*token, error) {
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return nil, errors.New("Token command printed no token")
+	}
+
+	// Anything that isn't a JSON object is the token itself
+	if output[0] != '{' {
+		if bytes.ContainsAny(output, "\r\n") {
+			return nil, errors.New("Token command printed more than one line")
+		}
+		return &token{AccessToken: string(output)}, nil
+	}
+
+	var out commandOutput
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, fmt.Errorf("Invalid JSON from token command: %s", err.Error())
+	}
+
+	t := &token{AccessToken: out.Token}
+	expiry := out.Expiry
+	if out.AccessToken != "" {
+		t.AccessToken = out.AccessToken
+	}
+	if out.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(out.ExpiresIn) * time.Second)
+	}
+	if out.Status != nil {
+		t.AccessToken = out.Status.Token
+		expiry = out.Status.ExpirationTimestamp
+	}
+	if t.AccessToken == "" {
+		return nil, errors.New("JSON from token command contains no token")
+	}
+	if strings.ContainsAny(t.AccessToken, "\r\n") {
+		return nil, errors.New("Token from token command contains a line break")
+	}
+
+	t.Expiry = expiry
+	return t, nil
+}
@@ -0,0 +1,68 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCommandOutput(t *testing.T) {
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		output string
+		token  string
+		expiry time.Time
+	}{
+		{"plain-token\n", "plain-token", time.Time{}},
+		{`{"token":"json-token","expiry":"2030-01-02T03:04:05Z"}`, "json-token", expiry},
+		{`{"access_token":"oauth-token"}`, "oauth-token", time.Time{}},
+		{`{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"k8s-token","expirationTimestamp":"2030-01-02T03:04:05Z"}}`, "k8s-token", expiry},
+	}
+	for _, test := range tests {
+		got, err := parseCommandOutput([]byte(test.output))
+		if err != nil {
+			t.Errorf("parseCommandOutput(%q): %s", test.output, err)
+			continue
+		}
+		if got.AccessToken != test.token || !got.Expiry.Equal(test.expiry) {
+			t.Errorf("parseCommandOutput(%q) = %q expiring %s, want %q expiring %s", test.output, got.AccessToken, got.Expiry, test.token, test.expiry)
+		}
+	}
+
+	for _, output := range []string{"", "two\nlines", `{"expires_in":60}`, `{"token":`} {
+		if _, err := parseCommandOutput([]byte(output)); err == nil {
+			t.Errorf("parseCommandOutput(%q) succeeded", output)
+		}
+	}
+}
+
+// TestCommandExpiredToken checks that a token reported as already expired is still used
+// for a while instead of running the command for every request
+func TestCommandExpiredToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The test command uses sh")
+	}
+
+	runs := filepath.Join(t.TempDir(), "runs")
+	c := NewCommand(`echo run >> '`+runs+`'; echo '{"token":"stale-token","expiry":"2000-01-01T00:00:00Z"}'`, nil)
+	for i := 0; i < 3; i++ {
+		token, err := c.Token(context.Background())
+		if err != nil || token != "stale-token" {
+			t.Fatalf("Token returned %q, %v", token, err)
+		}
+	}
+
+	content, _ := os.ReadFile(runs)
+	if n := strings.Count(string(content), "run"); n != 1 {
+		t.Errorf("Command ran %d times, want 1", n)
+	}
+}
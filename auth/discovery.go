@@ -0,0 +1,183 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxMetadataSize limits the size of metadata and token responses
+const maxMetadataSize = 1 << 20
+
+// resourceMetadata is OAuth 2.0 Protected Resource Metadata (RFC 9728)
+type resourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+	ScopesSupported      []string `json:"scopes_supported"`
+}
+
+// serverMetadata is OAuth 2.0 Authorization Server Metadata (RFC 8414)
+// OpenID Connect discovery documents use the same field names.
+type serverMetadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	RegistrationEndpoint          string   `json:"registration_endpoint"`
+	ScopesSupported               []string `json:"scopes_supported"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+	GrantTypesSupported           []string `json:"grant_types_supported"`
+}
+
+// canonicalResource returns the canonical URI of the MCP server for the resource parameter (RFC 8707)
+// The scheme and host are lowercased and any query or fragment is removed.
+func canonicalResource(serverURL string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return serverURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.RawQuery = ""
+	u.Fragment = ""
+	return strings.TrimSuffix(u.String(), "/")
+}
+
+// wellKnownURLs returns the URLs at which metadata for base may be published
+// The well-known suffix is inserted between the host and the path (RFC 8414 section 3.1,
+// RFC 9728 section 3.1).
+func wellKnownURLs(base *url.URL, suffixes ...string) []string {
+	path := strings.TrimSuffix(base.EscapedPath(), "/")
+	origin := fmt.Sprintf("%s://%s", base.Scheme, base.Host)
+
+	var urls []string
+	for _, suffix := range suffixes {
+		urls = append(urls, origin+"/.well-known/"+suffix+path)
+	}
+	return urls
+}
+
+// getJSON fetches a JSON document into v
+func (o *OAuth) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("GET %s returned HTTP %d", rawURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("Invalid JSON from %s: %s", rawURL, err.Error())
+	}
+	return nil
+}
+
+// discoverResource fetches the protected resource metadata of the MCP server
+// metadataURL comes from the resource_metadata parameter of the server's challenge. If the
+// server didn't provide one the well-known locations are tried.
+func (o *OAuth) discoverResource(ctx context.Context, metadataURL string) (*resourceMetadata, error) {
+	var candidates []string
+	if metadataURL != "" {
+		candidates = []string{metadataURL}
+	} else {
+		u, err := url.Parse(o.serverURL)
+		if err != nil {
+			return nil, err
+		}
+		candidates = wellKnownURLs(u, "oauth-protected-resource")
+		if u.Path != "" && u.Path != "/" {
+			candidates = append(candidates, fmt.Sprintf("%s://%s/.well-known/oauth-protected-resource", u.Scheme, u.Host))
+		}
+	}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		var metadata resourceMetadata
+		if err := o.getJSON(ctx, candidate, &metadata); err != nil {
+			lastErr = err
+			continue
+		}
+		if len(metadata.AuthorizationServers) == 0 {
+			return nil, fmt.Errorf("Protected resource metadata at %s lists no authorization servers", candidate)
+		}
+
+		// The metadata must describe this server (RFC 9728 section 3.3)
+		if metadata.Resource != "" && !strings.HasPrefix(canonicalResource(o.serverURL), canonicalResource(metadata.Resource)) {
+			return nil, fmt.Errorf("Protected resource metadata is for %s, not %s", metadata.Resource, o.serverURL)
+		}
+
+		o.logger.Printf("OAuth: protected resource metadata from %s", candidate)
+		return &metadata, nil
+	}
+	return nil, fmt.Errorf("Failed to fetch protected resource metadata: %s", lastErr.Error())
+}
+
+// discoverServer fetches the metadata of an authorization server
+// Both OAuth and OpenID Connect discovery locations are tried, as the MCP specification requires.
+func (o *OAuth) discoverServer(ctx context.Context, issuer string) (*serverMetadata, error) {
+	u, err := checkURL("authorization server", issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := wellKnownURLs(u, "oauth-authorization-server", "openid-configuration")
+	if path := strings.TrimSuffix(u.EscapedPath(), "/"); path != "" {
+		candidates = append(candidates, fmt.Sprintf("%s://%s%s/.well-known/openid-configuration", u.Scheme, u.Host, path))
+	}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		var metadata serverMetadata
+		if err = o.getJSON(ctx, candidate, &metadata); err != nil {
+			lastErr = err
+			continue
+		}
+
+		if metadata.Issuer != "" && strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+			return nil, fmt.Errorf("Authorization server metadata at %s is for issuer %s", candidate, metadata.Issuer)
+		}
+		if _, err = checkURL("authorization endpoint", metadata.AuthorizationEndpoint); err != nil {
+			return nil, err
+		}
+		if _, err = checkURL("token endpoint", metadata.TokenEndpoint); err != nil {
+			return nil, err
+		}
+		if metadata.RegistrationEndpoint != "" {
+			if _, err = checkURL("registration endpoint", metadata.RegistrationEndpoint); err != nil {
+				return nil, err
+			}
+		}
+
+		// PKCE with S256 is mandatory
+		if len(metadata.CodeChallengeMethodsSupported) > 0 && !slices.Contains(metadata.CodeChallengeMethodsSupported, "S256") {
+			return nil, fmt.Errorf("Authorization server %s does not support PKCE with S256", issuer)
+		}
+
+		o.logger.Printf("OAuth: authorization server metadata from %s", candidate)
+		return &metadata, nil
+	}
+	return nil, fmt.Errorf("Failed to fetch authorization server metadata for %s: %s", issuer, lastErr.Error())
+}
@@ -0,0 +1,164 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// assertionType is the client_assertion_type of a JWT client assertion (RFC 7523 section 2.2)
+const assertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// assertionLifetime is how long a client assertion is valid
+const assertionLifetime = 5 * time.Minute
+
+// signingKey is a private key used to sign client assertions
+type signingKey struct {
+	key       crypto.Signer
+	algorithm string // JWS algorithm (RFC 7518)
+	keyID     string // kid header, may be empty
+}
+
+// loadSigningKey reads a PEM private key in PKCS#8, PKCS#1 or SEC 1 format
+// The algorithm follows from the key: RS256 for RSA, ES256/ES384/ES512 for ECDSA depending
+// on the curve, and EdDSA for Ed25519.
+func loadSigningKey(file string, keyID string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read private key: %s", err.Error())
+	}
+
+	var key interface{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("No private key found in %s", file)
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid private key in %s: %s", file, err.Error())
+		}
+		break
+	}
+
+	signer := &signingKey{keyID: keyID}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signer.key, signer.algorithm = k, "RS256"
+	case *ecdsa.PrivateKey:
+		signer.key = k
+		switch k.Curve {
+		case elliptic.P256():
+			signer.algorithm = "ES256"
+		case elliptic.P384():
+			signer.algorithm = "ES384"
+		case elliptic.P521():
+			signer.algorithm = "ES512"
+		default:
+			return nil, fmt.Errorf("Unsupported elliptic curve in %s", file)
+		}
+	case ed25519.PrivateKey:
+		signer.key, signer.algorithm = k, "EdDSA"
+	default:
+		return nil, fmt.Errorf("Unsupported private key type in %s", file)
+	}
+	return signer, nil
+}
+
+// sign returns a signed JWT with the given claims (RFC 7515 compact serialization)
+func (s *signingKey) sign(claims map[string]interface{}) (string, error) {
+	header := map[string]interface{}{"alg": s.algorithm, "typ": "JWT"}
+	if s.keyID != "" {
+		header["kid"] = s.keyID
+	}
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(input))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	case *ecdsa.PrivateKey:
+		signature, err = signECDSA(key, []byte(input))
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(input))
+	default:
+		err = errors.New("Unsupported signing key")
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to sign client assertion: %s", err.Error())
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// signECDSA returns an ECDSA signature as the fixed-size r || s that JWS requires
+func signECDSA(key *ecdsa.PrivateKey, input []byte) ([]byte, error) {
+	var digest []byte
+	switch key.Curve {
+	case elliptic.P256():
+		sum := sha256.Sum256(input)
+		digest = sum[:]
+	case elliptic.P384():
+		sum := sha512.Sum384(input)
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512(input)
+		digest = sum[:]
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+	return signature, nil
+}
+
+// clientAssertion returns a client assertion for the token endpoint (RFC 7523 section 3)
+func (s *signingKey) clientAssertion(clientID string, tokenEndpoint string) (string, error) {
+	now := time.Now()
+	return s.sign(map[string]interface{}{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": tokenEndpoint,
+		"jti": randomString(),
+		"iat": now.Unix(),
+		"exp": now.Add(assertionLifetime).Unix(),
+	})
+}
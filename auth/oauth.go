@@ -0,0 +1,298 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// OAuthConfig configures the MCP authorization flow
+// The zero value uses dynamic client registration and the scopes the server asks for.
+type OAuthConfig struct {
+	ClientID     string   // pre-registered client ID, skips dynamic client registration
+	ClientSecret string   // secret of the pre-registered client, empty for a public client
+	Scopes       []string // scopes to request instead of those advertised by the server
+	RedirectPort int      // port of the loopback redirect listener, 0 for any free port
+	CacheDir     string   // token cache directory, default is mcprelay/oauth in the user cache directory
+	NoBrowser    bool     // only log the authorization URL instead of opening a browser
+}
+
+// OAuth implements the authorization flow of the MCP specification
+// See https://modelcontextprotocol.io/specification/2025-06-18/basic/authorization
+// On the server's first 401 the protected resource and authorization server metadata are
+// discovered, the relay registers itself as a client if needed, and the user authorizes
+// access in their browser (authorization code flow with PKCE and a loopback redirect).
+// Tokens are cached on disk and refreshed automatically.
+type OAuth struct {
+	serverURL string // URL of the MCP server
+	resource  string // canonical URI of the MCP server (RFC 8707)
+	config    OAuthConfig
+	client    *http.Client
+	logger    Logger
+	cache     tokenCache
+
+	mutex  sync.Mutex  // serializes token requests so that concurrent 401s cause one authorization
+	entry  *cacheEntry // current client registration and token, nil if none
+	loaded bool        // the cache has been read
+}
+
+// NewOAuth creates a token source for the MCP server at serverURL
+// client is used for all requests to the authorization server.
+func NewOAuth(serverURL string, cfg OAuthConfig, client *http.Client, logger Logger) *OAuth {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = defaultCacheDir()
+	}
+
+	return &OAuth{
+		serverURL: serverURL,
+		resource:  canonicalResource(serverURL),
+		config:    cfg,
+		client:    client,
+		logger:    logger,
+		cache:     tokenCache{dir: cfg.CacheDir},
+	}
+}
+
+// load reads the token cache once
+// The caller must hold the mutex.
+func (o *OAuth) load() {
+	if o.loaded {
+		return
+	}
+	o.loaded = true
+
+	entry, err := o.cache.load(o.resource)
+	if err != nil {
+		o.logger.Printf("OAuth: failed to read token cache: %s", err.Error())
+		return
+	}
+	if entry != nil {
+		o.logger.Printf("OAuth: using cached token for %s", o.resource)
+	}
+	o.entry = entry
+}
+
+// save writes the current state to the token cache
+// The caller must hold the mutex.
+func (o *OAuth) save() {
+	if o.entry == nil {
+		return
+	}
+	if err := o.cache.save(o.entry); err != nil {
+		o.logger.Printf("OAuth: failed to write token cache: %s", err.Error())
+	}
+}
+
+// Token returns the cached access token, refreshing it if it has expired
+func (o *OAuth) Token(ctx context.Context) (string, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.load()
+	if o.entry == nil || o.entry.Token == nil {
+		return "", nil
+	}
+	if o.entry.Token.valid() {
+		return o.entry.Token.AccessToken, nil
+	}
+
+	if o.entry.Token.RefreshToken != "" {
+		if err := o.refresh(ctx); err == nil {
+			return o.entry.Token.AccessToken, nil
+		}
+	}
+
+	// Without a usable token the server responds with 401, which starts authorization
+	return "", nil
+}
+
+// Unauthorized obtains a new token after the server rejected sent
+// A refresh is tried first; if that isn't possible the user is asked to authorize access.
+func (o *OAuth) Unauthorized(ctx context.Context, sent string, challenge string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.load()
+
+	// Another request may have obtained a new token in the meantime
+	if o.entry != nil && o.entry.Token.valid() && o.entry.Token.AccessToken != sent {
+		return nil
+	}
+
+	params := parseChallenge(challenge)
+	if params["error"] != "insufficient_scope" && o.entry != nil && o.entry.Token != nil && o.entry.Token.RefreshToken != "" {
+		if err := o.refresh(ctx); err == nil {
+			return nil
+		}
+	}
+
+	// The browser flow must not be aborted because the request that triggered it was
+	// cancelled, as other requests are waiting for it too
+	return o.authorize(context.WithoutCancel(ctx), params)
+}
+
+// refresh exchanges the refresh token for a new access token
+// The token is discarded if the refresh fails. The caller must hold the mutex.
+func (o *OAuth) refresh(ctx context.Context) error {
+	client := o.registration()
+	if client == nil {
+		o.entry.Token = nil
+		return errors.New("No client registration for the refresh token")
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {o.entry.Token.RefreshToken},
+		"resource":      {o.resource},
+	}
+	t, err := requestToken(ctx, o.client, o.entry.TokenEndpoint, form, clientAuthentication(client), o.entry.Token)
+	if err != nil {
+		o.logger.Printf("OAuth: failed to refresh token: %s", err.Error())
+
+		// A refresh token rejected by the server is useless, but keep it after a network error
+		var tokenErr *tokenError
+		if errors.As(err, &tokenErr) {
+			o.entry.Token = nil
+			o.save()
+		}
+		return err
+	}
+
+	o.logger.Println("OAuth: refreshed access token")
+	o.entry.Token = t
+	o.save()
+	return nil
+}
+
+// registration returns the client to use with the cached authorization server
+func (o *OAuth) registration() *registration {
+	if o.config.ClientID != "" {
+		return o.configuredClient("")
+	}
+	if o.entry != nil {
+		return o.entry.Client
+	}
+	return nil
+}
+
+// configuredClient returns the pre-registered client from the configuration
+func (o *OAuth) configuredClient(redirectURI string) *registration {
+	client := &registration{ClientID: o.config.ClientID, ClientSecret: o.config.ClientSecret, AuthMethod: "none", RedirectURI: redirectURI}
+	if client.ClientSecret != "" {
+		client.AuthMethod = "client_secret_basic"
+	}
+	return client
+}
+
+// authorize runs discovery, registration and the browser flow to obtain a new token
+// The caller must hold the mutex.
+func (o *OAuth) authorize(ctx context.Context, challenge map[string]string) error {
+	resourceMeta, err := o.discoverResource(ctx, challenge["resource_metadata"])
+	if err != nil {
+		return err
+	}
+	issuer := resourceMeta.AuthorizationServers[0]
+	serverMeta, err := o.discoverServer(ctx, issuer)
+	if err != nil {
+		return err
+	}
+
+	// Explicitly configured scopes take precedence over those the server asks for
+	scope := strings.Join(o.config.Scopes, " ")
+	if scope == "" {
+		scope = challenge["scope"]
+	}
+	if scope == "" {
+		scope = strings.Join(resourceMeta.ScopesSupported, " ")
+	}
+
+	// A dynamically registered client is reused while its redirect URI is available
+	var cached *registration
+	if o.config.ClientID == "" && o.entry != nil && o.entry.Issuer == issuer {
+		cached = o.entry.Client
+	}
+	previousRedirect := ""
+	if cached != nil {
+		previousRedirect = cached.RedirectURI
+	}
+	listener, redirectURI, err := listenLoopback(o.config.RedirectPort, previousRedirect)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	var client *registration
+	switch {
+	case o.config.ClientID != "":
+		client = o.configuredClient(redirectURI)
+	case cached != nil && cached.RedirectURI == redirectURI:
+		client = cached
+	case serverMeta.RegistrationEndpoint == "":
+		return fmt.Errorf("Authorization server %s does not support dynamic client registration, a client ID must be configured", issuer)
+	default:
+		if client, err = o.register(ctx, serverMeta.RegistrationEndpoint, redirectURI, scope); err != nil {
+			return err
+		}
+	}
+
+	code, verifier, err := o.authorizeInBrowser(ctx, listener, serverMeta, client, scope, o.resource)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {client.RedirectURI},
+		"code_verifier": {verifier},
+		"resource":      {o.resource},
+	}
+	t, err := requestToken(ctx, o.client, serverMeta.TokenEndpoint, form, clientAuthentication(client), nil)
+	if err != nil {
+		return err
+	}
+
+	o.logger.Printf("OAuth: authorized by %s", issuer)
+	o.entry = &cacheEntry{
+		Resource:      o.resource,
+		Issuer:        issuer,
+		TokenEndpoint: serverMeta.TokenEndpoint,
+		Client:        client,
+		Token:         t,
+	}
+	o.save()
+	return nil
+}
+
+// clientAuthentication returns how the client authenticates to the token endpoint (RFC 6749 section 2.3)
+func clientAuthentication(client *registration) clientAuth {
+	return func(req *http.Request, form url.Values) error {
+		switch client.AuthMethod {
+		case "client_secret_basic":
+			req.SetBasicAuth(url.QueryEscape(client.ClientID), url.QueryEscape(client.ClientSecret))
+		case "client_secret_post":
+			form.Set("client_id", client.ClientID)
+			form.Set("client_secret", client.ClientSecret)
+		default:
+			form.Set("client_id", client.ClientID)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,277 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authServer is an MCP server that is also its own authorization server
+// It supports dynamic client registration, the authorization code flow with PKCE and
+// refresh tokens.
+type authServer struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mutex        sync.Mutex
+	registered   int               // number of client registrations
+	redirectURI  string            // redirect URI of the registered client
+	challenge    string            // PKCE challenge of the pending authorization
+	issued       int               // number of access tokens issued
+	accessToken  string            // access token accepted by the MCP endpoint
+	refreshToken string            // refresh token accepted by the token endpoint
+	grants       map[string]string // grant type -> resource parameter of the last request
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	s := &authServer{t: t, grants: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", s.mcp)
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", s.resourceMetadata)
+	mux.HandleFunc("/.well-known/oauth-authorization-server", s.serverMetadata)
+	mux.HandleFunc("/register", s.register)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *authServer) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (s *authServer) mcp(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	valid := s.accessToken != "" && req.Header.Get("Authorization") == "Bearer "+s.accessToken
+	s.mutex.Unlock()
+
+	if !valid {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer resource_metadata="%s/.well-known/oauth-protected-resource/mcp"`, s.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *authServer) resourceMetadata(w http.ResponseWriter, req *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resource":              s.server.URL + "/mcp",
+		"authorization_servers": []string{s.server.URL},
+		"scopes_supported":      []string{"mcp"},
+	})
+}
+
+func (s *authServer) serverMetadata(w http.ResponseWriter, req *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                           s.server.URL,
+		"authorization_endpoint":           s.server.URL + "/authorize",
+		"token_endpoint":                   s.server.URL + "/token",
+		"registration_endpoint":            s.server.URL + "/register",
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+}
+
+func (s *authServer) register(w http.ResponseWriter, req *http.Request) {
+	var request struct {
+		RedirectURIs []string `json:"redirect_uris"`
+		AuthMethod   string   `json:"token_endpoint_auth_method"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil || len(request.RedirectURIs) != 1 || request.AuthMethod != "none" {
+		s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client_metadata"})
+		return
+	}
+
+	s.mutex.Lock()
+	s.registered++
+	s.redirectURI = request.RedirectURIs[0]
+	s.mutex.Unlock()
+	s.writeJSON(w, http.StatusCreated, map[string]interface{}{"client_id": "relay-client", "redirect_uris": request.RedirectURIs})
+}
+
+// authorize approves every request immediately, as a user would in the browser
+func (s *authServer) authorize(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	s.mutex.Lock()
+	redirectURI := s.redirectURI
+	s.challenge = query.Get("code_challenge")
+	s.mutex.Unlock()
+
+	switch {
+	case query.Get("client_id") != "relay-client", query.Get("redirect_uri") != redirectURI:
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	case query.Get("code_challenge_method") != "S256", query.Get("resource") != s.server.URL+"/mcp":
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, req, redirectURI+"?"+url.Values{"code": {"code-1"}, "state": {query.Get("state")}}.Encode(), http.StatusFound)
+}
+
+func (s *authServer) token(w http.ResponseWriter, req *http.Request) {
+	_ = req.ParseForm()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	grant := req.PostForm.Get("grant_type")
+	s.grants[grant] = req.PostForm.Get("resource")
+	switch grant {
+	case "authorization_code":
+		if req.PostForm.Get("code") != "code-1" || pkceChallenge(req.PostForm.Get("code_verifier")) != s.challenge ||
+			req.PostForm.Get("redirect_uri") != s.redirectURI {
+			s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	case "refresh_token":
+		if req.PostForm.Get("refresh_token") != s.refreshToken {
+			s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	default:
+		s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	if req.PostForm.Get("client_id") != "relay-client" {
+		s.writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.issued++
+	s.accessToken = fmt.Sprintf("access-%d", s.issued)
+	s.refreshToken = fmt.Sprintf("refresh-%d", s.issued)
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  s.accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+		"refresh_token": s.refreshToken,
+	})
+}
+
+// browser stands in for the user: it follows the authorization URL from the log
+type browser struct {
+	t *testing.T
+}
+
+func (b *browser) Write(p []byte) (int, error) {
+	const prefix = "OAuth: authorization required, open "
+	if i := bytes.Index(p, []byte(prefix)); i >= 0 {
+		authURL := strings.TrimSpace(string(p[i+len(prefix):]))
+		go func() {
+			resp, err := http.Get(authURL)
+			if err != nil {
+				b.t.Errorf("Authorization request failed: %s", err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				b.t.Errorf("Authorization returned HTTP %d", resp.StatusCode)
+			}
+		}()
+	}
+	return len(p), nil
+}
+
+// call sends a request to the MCP server and returns the status and challenge
+func call(t *testing.T, o *OAuth) (int, string) {
+	t.Helper()
+	token, err := o.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %s", err)
+	}
+	req, _ := http.NewRequest("POST", o.serverURL, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %s", err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("WWW-Authenticate")
+}
+
+func TestOAuthFlow(t *testing.T) {
+	// The authorization URL is also printed to stderr for the user
+	stderr := os.Stderr
+	os.Stderr, _ = os.Open(os.DevNull)
+	defer func() {
+		_ = os.Stderr.Close()
+		os.Stderr = stderr
+	}()
+
+	s := newAuthServer(t)
+	cacheDir := t.TempDir()
+	logger := log.New(&browser{t: t}, "", 0)
+	o := NewOAuth(s.server.URL+"/mcp", OAuthConfig{NoBrowser: true, CacheDir: cacheDir}, nil, logger)
+
+	// Without a token the server asks for authorization
+	status, challenge := call(t, o)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("Expected HTTP 401, got %d", status)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := o.Unauthorized(ctx, "", challenge); err != nil {
+		t.Fatalf("Unauthorized: %s", err)
+	}
+	if status, _ = call(t, o); status != http.StatusOK {
+		t.Fatalf("Expected HTTP 200 after authorization, got %d", status)
+	}
+	s.mutex.Lock()
+	if s.registered != 1 {
+		t.Errorf("Expected one client registration, got %d", s.registered)
+	}
+	s.mutex.Unlock()
+
+	// An expired token is refreshed
+	o.mutex.Lock()
+	o.entry.Token.Expiry = time.Now().Add(-time.Second)
+	o.mutex.Unlock()
+	if token, _ := o.Token(context.Background()); token != "access-2" {
+		t.Fatalf("Expected refreshed token access-2, got %q", token)
+	}
+	if status, _ = call(t, o); status != http.StatusOK {
+		t.Fatalf("Expected HTTP 200 after refresh, got %d", status)
+	}
+
+	// Every token request names the MCP server as the resource
+	s.mutex.Lock()
+	for grant, resource := range s.grants {
+		if resource != s.server.URL+"/mcp" {
+			t.Errorf("Token request for %s has resource %q", grant, resource)
+		}
+	}
+	s.mutex.Unlock()
+
+	// The token and registration are cached for the next run
+	cached := NewOAuth(s.server.URL+"/mcp", OAuthConfig{NoBrowser: true, CacheDir: cacheDir}, nil, nil)
+	if token, _ := cached.Token(context.Background()); token != "access-2" {
+		t.Fatalf("Expected cached token access-2, got %q", token)
+	}
+	info, err := os.Stat(cached.cache.path(cached.resource))
+	if err != nil {
+		t.Fatalf("Token cache: %s", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Token cache has permissions %o", info.Mode().Perm())
+	}
+}
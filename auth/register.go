@@ -0,0 +1,75 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// clientName is the name shown to the user by the authorization server
+const clientName = "MCPRelay"
+
+// register registers the relay as an OAuth client using Dynamic Client Registration (RFC 7591)
+// The relay is a public client, so no secret is requested; the server may issue one anyway.
+func (o *OAuth) register(ctx context.Context, endpoint string, redirectURI string, scope string) (*registration, error) {
+	request := map[string]interface{}{
+		"client_name":                clientName,
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	}
+	if scope != "" {
+		request["scope"] = scope
+	}
+	body, _ := json.Marshal(request)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Client registration failed: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	if err != nil {
+		return nil, fmt.Errorf("Client registration failed: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		regErr := &tokenError{Status: resp.StatusCode}
+		_ = json.Unmarshal(respBody, regErr)
+		return nil, fmt.Errorf("Client registration failed with HTTP %d: %s %s", resp.StatusCode, regErr.Code, regErr.Description)
+	}
+
+	var client registration
+	if err = json.Unmarshal(respBody, &client); err != nil {
+		return nil, fmt.Errorf("Invalid client registration response: %s", err.Error())
+	}
+	if client.ClientID == "" {
+		return nil, fmt.Errorf("Client registration response contains no client_id")
+	}
+	if client.AuthMethod == "" {
+		client.AuthMethod = "none"
+		if client.ClientSecret != "" {
+			client.AuthMethod = "client_secret_basic"
+		}
+	}
+	client.RedirectURI = redirectURI
+
+	o.logger.Printf("OAuth: registered client %s", client.ClientID)
+	return &client, nil
+}
@@ -0,0 +1,128 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// expiryMargin is how long before its expiry a token is refreshed
+// Tokens with a short lifetime are refreshed after half of it instead.
+const expiryMargin = 60 * time.Second
+
+// token is an access token and the refresh token that came with it
+type token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"` // when to refresh, zero if the server didn't say
+	Scope        string    `json:"scope,omitempty"`
+}
+
+// valid returns true if the access token can be used
+func (t *token) valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Before(t.Expiry)
+}
+
+// refreshTime returns when to refresh a token that expires after lifetime
+func refreshTime(lifetime time.Duration) time.Time {
+	return time.Now().Add(lifetime - min(expiryMargin, lifetime/2))
+}
+
+// tokenResponse is a successful token endpoint response (RFC 6749 section 5.1)
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// tokenError is an error response from the token endpoint (RFC 6749 section 5.2)
+type tokenError struct {
+	Status      int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *tokenError) Error() string {
+	msg := fmt.Sprintf("Token request failed with HTTP %d", e.Status)
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Code)
+	}
+	if e.Description != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Description)
+	}
+	return msg
+}
+
+// clientAuth adds client authentication to a token request
+type clientAuth func(req *http.Request, form url.Values) error
+
+// requestToken sends a request to a token endpoint and returns the token it issues
+// previous is the token being refreshed, whose refresh token is kept if no new one is issued.
+func requestToken(ctx context.Context, client *http.Client, endpoint string, form url.Values, authenticate clientAuth, previous *token) (*token, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authenticate != nil {
+		if err = authenticate(req, form); err != nil {
+			return nil, err
+		}
+	}
+	body := form.Encode()
+	req.Body = io.NopCloser(strings.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := &tokenError{Status: resp.StatusCode}
+		_ = json.Unmarshal(respBody, tokenErr)
+		return nil, tokenErr
+	}
+
+	var tr tokenResponse
+	if err = json.Unmarshal(respBody, &tr); err != nil {
+		return nil, fmt.Errorf("Invalid token response: %s", err.Error())
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("Token response contains no access token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "Bearer") {
+		return nil, fmt.Errorf("Unsupported token type %s", tr.TokenType)
+	}
+
+	t := &token{AccessToken: tr.AccessToken, RefreshToken: tr.RefreshToken, Scope: tr.Scope}
+	if tr.ExpiresIn > 0 {
+		t.Expiry = refreshTime(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	if t.RefreshToken == "" && previous != nil {
+		t.RefreshToken = previous.RefreshToken
+	}
+	return t, nil
+}
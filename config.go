@@ -0,0 +1,186 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is the prefix of environment variables that set options
+const envPrefix = "MCPRELAY_"
+
+// configFile is the structure of the configuration file
+// Options use the same names as the command-line flags. Those in common apply to every
+// profile, and a profile's own options take precedence over them.
+type configFile struct {
+	Common   map[string]interface{}            `yaml:"common"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/mcprelay/config.yaml, or its equivalent on
+// platforms without XDG
+func defaultConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			return ""
+		}
+	}
+	return filepath.Join(dir, "mcprelay", "config.yaml")
+}
+
+// envName returns the environment variable for a flag, e.g. MCPRELAY_TLS_CERT for -tls-cert
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// flagOrEnv returns the value of a flag if it was set on the command line, otherwise that
+// of its environment variable
+func flagOrEnv(value string, name string, set map[string]bool) string {
+	if set[name] {
+		return value
+	}
+	return os.Getenv(envName(name))
+}
+
+// applyConfig sets the flags that weren't given on the command line, first from MCPRELAY_*
+// environment variables and then from the selected profile of the configuration file
+// It can be called again to reload the configuration file. It returns the path of the
+// configuration file, even if it doesn't exist, and a description of where the
+// configuration came from for the log.
+func applyConfig(configPath string, profile string) (string, string, error) {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	configPath = flagOrEnv(configPath, "config", set)
+	profile = flagOrEnv(profile, "profile", set)
+
+	// Environment variables
+	var errs []error
+	flag.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || f.Name == "config" || f.Name == "profile" {
+			return
+		}
+
+		// Undo what a previous call set, in case the option was removed from the profile
+		if list, ok := f.Value.(*headerList); ok {
+			*list = nil
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			if err := f.Value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("Invalid value for %s: %s", envName(f.Name), err.Error()))
+			}
+			set[f.Name] = true
+		}
+	})
+	if len(errs) > 0 {
+		return "", "", errors.Join(errs...)
+	}
+
+	// Configuration file, which is optional unless it was named or a profile is selected
+	explicit := configPath != ""
+	if !explicit {
+		configPath = defaultConfigPath()
+	}
+	content, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) && !explicit && profile == "" {
+		return configPath, "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to read configuration file: %s", err.Error())
+	}
+
+	var cfg configFile
+	if err = yaml.Unmarshal(content, &cfg); err != nil {
+		return "", "", fmt.Errorf("Failed to parse configuration file %s: %s", configPath, err.Error())
+	}
+
+	options := make(map[string]interface{})
+	for name, value := range cfg.Common {
+		options[name] = value
+	}
+	if profile != "" {
+		selected, ok := cfg.Profiles[profile]
+		if !ok {
+			return "", "", fmt.Errorf("Profile %s not found in %s", profile, configPath)
+		}
+		for name, value := range selected {
+			options[name] = value
+		}
+	}
+
+	// Apply options in a fixed order so that errors are reported consistently
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || name == "profile" || flag.Lookup(name) == nil {
+			return "", "", fmt.Errorf("Unknown option %s in %s", name, configPath)
+		}
+		if set[name] {
+			continue
+		}
+		if err = setOption(name, options[name]); err != nil {
+			return "", "", fmt.Errorf("Invalid value for %s in %s: %s", name, configPath, err.Error())
+		}
+	}
+
+	if profile == "" {
+		return configPath, configPath, nil
+	}
+	return configPath, fmt.Sprintf("profile %s from %s", profile, configPath), nil
+}
+
+// setOption sets a flag from a value in the configuration file
+// Objects (e.g. headers) are converted to JSON and lists are joined with commas, as the
+// flags expect. Each element of a list is set separately for repeatable flags (header).
+func setOption(name string, value interface{}) error {
+	f := flag.Lookup(name)
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return f.Value.Set(string(encoded))
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		if _, repeatable := f.Value.(*headerList); repeatable {
+			for _, item := range items {
+				if err := f.Value.Set(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return f.Value.Set(strings.Join(items, ","))
+	default:
+		return f.Value.Set(fmt.Sprint(v))
+	}
+}
@@ -20,16 +20,19 @@ type Logger = *log.Logger
 // Data is this package's object
 // Critical data is not exported and must be accessed through methods
 type Data struct {
-	server  string       // server (protocol://host:port)
-	sseURL  string       // sseURL (server + path)
-	postURL string       // postURL (server + path)
-	logger  Logger       // logger
-	mutex   sync.RWMutex // Read/Write mutex
+	server  string            // server (protocol://host:port)
+	sseURL  string            // sseURL (server + path)
+	postURL string            // postURL (server + path)
+	session string            // MCP session ID for HTTP transport
+	version string            // MCP protocol version negotiated during initialization
+	eventID map[string]string // last SSE event ID received on each stream
+	logger  Logger            // logger
+	mutex   sync.RWMutex      // Read/Write mutex
 }
 
 // New creates a new Data object
 func New(logger Logger) *Data {
-	data := &Data{logger: logger}
+	data := &Data{logger: logger, eventID: make(map[string]string)}
 
 	// Protect against nil logger
 	if data.logger == nil {
@@ -90,3 +93,50 @@ func (d *Data) GetPostURL() string {
 	defer d.mutex.RUnlock()
 	return d.postURL
 }
+
+func (d *Data) SetSessionID(id string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.session = id
+	d.logger.Printf("Session ID set to %s", id)
+}
+
+func (d *Data) GetSessionID() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.session
+}
+
+// SetLastEventID records the ID of the last event received on an SSE stream
+func (d *Data) SetLastEventID(stream string, id string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.eventID[stream] = id
+}
+
+// GetLastEventID returns the ID of the last event received on an SSE stream, if any
+func (d *Data) GetLastEventID(stream string) string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.eventID[stream]
+}
+
+// ClearLastEventID forgets the last event ID of a stream that will not be resumed
+func (d *Data) ClearLastEventID(stream string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.eventID, stream)
+}
+
+func (d *Data) SetProtocolVersion(version string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.version = version
+	d.logger.Printf("Protocol version set to %s", version)
+}
+
+func (d *Data) GetProtocolVersion() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.version
+}
@@ -1,3 +1,14 @@
 module github.com/PivotLLM/MCPRelay
 
-go 1.24
+go 1.24.0
+
+require (
+	golang.org/x/net v0.50.0
+	gopkg.in/yaml.v3 v3.0.1
+	software.sslmate.com/src/go-pkcs12 v0.7.3
+)
+
+require (
+	golang.org/x/crypto v0.48.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+)
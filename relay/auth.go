@@ -0,0 +1,108 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/PivotLLM/MCPRelay/auth"
+)
+
+// newTokenSource creates the token source selected by the configuration, or returns nil
+// if none is configured
+func (r *Relay) newTokenSource(cfg Config) (auth.TokenSource, error) {
+	if cfg.TokenCommand != "" {
+		r.logger.Println("Using token command for authorization")
+		return auth.NewCommand(cfg.TokenCommand, r.logger), nil
+	}
+	if cfg.OAuth == nil && cfg.ClientCredentials == nil {
+		return nil, nil
+	}
+
+	if cfg.ClientCredentials != nil {
+		tokens, err := auth.NewClientCredentials(r.serverURL, *cfg.ClientCredentials, r.authClient, r.logger)
+		if err != nil {
+			return nil, err
+		}
+		r.logger.Println("OAuth client credentials grant enabled")
+		return tokens, nil
+	}
+	r.logger.Println("OAuth authorization enabled")
+	return auth.NewOAuth(r.serverURL, *cfg.OAuth, r.authClient, r.logger), nil
+}
+
+// tokenSource returns the current token source, nil if none is configured
+func (r *Relay) tokenSource() auth.TokenSource {
+	r.settingsMutex.RLock()
+	defer r.settingsMutex.RUnlock()
+	return r.tokens
+}
+
+// addHeaders adds the custom headers and the access token, if any, to a request
+func (r *Relay) addHeaders(req *http.Request) {
+	r.settingsMutex.RLock()
+	headers := r.headers
+	r.settingsMutex.RUnlock()
+
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	r.authorize(req)
+}
+
+// authorize adds the access token from the token source, if any, to a request
+// It replaces an Authorization header set with -headers.
+func (r *Relay) authorize(req *http.Request) {
+	tokens := r.tokenSource()
+	if tokens == nil {
+		return
+	}
+
+	token, err := tokens.Token(req.Context())
+	if err != nil {
+		r.logger.Printf("Failed to obtain access token: %s", err.Error())
+		r.flushLog()
+		return
+	}
+	if token != "" {
+		r.redactor.add(token)
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// reauthorize obtains a new access token if the server rejected the token of a request
+// This applies to 401, and to 403 with error="insufficient_scope" (step-up authorization).
+// It returns true if a new token is available and the request should be retried.
+func (r *Relay) reauthorize(ctx context.Context, resp *http.Response) bool {
+	tokens := r.tokenSource()
+	if tokens == nil {
+		return false
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+	case http.StatusForbidden:
+		if !strings.Contains(challenge, "insufficient_scope") {
+			return false
+		}
+	default:
+		return false
+	}
+
+	sent := strings.TrimPrefix(resp.Request.Header.Get("Authorization"), "Bearer ")
+	r.logger.Printf("Server returned HTTP %d, requesting a new access token", resp.StatusCode)
+	r.flushLog()
+
+	if err := tokens.Unauthorized(ctx, sent, challenge); err != nil {
+		r.logger.Printf("Authorization failed: %s", err.Error())
+		r.flushLog()
+		return false
+	}
+	return true
+}
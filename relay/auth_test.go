@@ -0,0 +1,77 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAuthClientWithoutTLSOptions(t *testing.T) {
+	r := newTestRelay(t, Config{
+		Endpoint: "https://mcp.example.com/mcp",
+		Proxy:    "http://proxy.example.com:3128",
+		TLS:      TLSOptions{ServerName: "internal.example.com", Pins: []string{"sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}},
+	})
+	if r.authClient == r.httpClient {
+		t.Fatal("Authorization server client shares the MCP server's TLS options")
+	}
+
+	transport := r.authClient.Transport.(*http.Transport)
+	if transport.TLSClientConfig != nil {
+		t.Errorf("Authorization server client has TLS options: %+v", transport.TLSClientConfig)
+	}
+	req, _ := http.NewRequest("POST", "https://auth.example.com/token", nil)
+	if proxy, err := transport.Proxy(req); err != nil || proxy == nil || proxy.Host != "proxy.example.com:3128" {
+		t.Errorf("Authorization server client does not use the proxy: %v, %v", proxy, err)
+	}
+}
+
+// staticTokens is a token source whose new tokens the server never accepts
+type staticTokens struct {
+	mutex        sync.Mutex
+	unauthorized int
+}
+
+func (s *staticTokens) Token(ctx context.Context) (string, error) {
+	return "rejected-token", nil
+}
+
+func (s *staticTokens) Unauthorized(ctx context.Context, token string, challenge string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.unauthorized++
+	return nil
+}
+
+func TestGETStreamRejectedToken(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	r := newTestRelay(t, Config{Endpoint: server.URL})
+	tokens := &staticTokens{}
+	r.tokens = tokens
+
+	// One immediate retry with the new token, after which the caller backs off
+	connected, retry := r.listenOnce(context.Background())
+	if connected || !retry {
+		t.Errorf("listenOnce returned connected=%v retry=%v, want false, true", connected, retry)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected 2 GET requests, got %d", n)
+	}
+	if tokens.unauthorized != 1 {
+		t.Errorf("Expected 1 new token, got %d", tokens.unauthorized)
+	}
+}
@@ -0,0 +1,78 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// runAuto detects the transport using the backwards compatibility procedure in the MCP specification
+// The client's first message (normally initialize) is POSTed to the URL. If the server rejects it
+// with 400, 404 or 405 it is treated as a legacy HTTP+SSE server and the URL is opened as an SSE
+// stream, which announces the POST endpoint in an endpoint event. Otherwise Streamable HTTP is used.
+func (r *Relay) runAuto(ctx context.Context, stdinChan <-chan string, stdinErrChan <-chan error) {
+	endpoint := r.data.GetPostURL()
+
+	// Nothing can be detected until the client sends its first message
+	var line string
+	select {
+	case <-ctx.Done():
+		r.logger.Println("Received termination signal, shutting down")
+		r.flushLog()
+		return
+	case err := <-stdinErrChan:
+		if err == io.EOF {
+			r.logger.Println("EOF on stdin, client closed connection")
+		} else {
+			r.logger.Printf("stdin error: %s", err.Error())
+		}
+		r.flushLog()
+		return
+	case line = <-stdinChan:
+	}
+
+	var status int
+	response := r.forwardHTTP(line, func(code int) bool {
+		switch code {
+		case http.StatusBadRequest, http.StatusNotFound, http.StatusMethodNotAllowed:
+			status = code
+			return true
+		}
+		return false
+	})
+
+	if status == 0 {
+		r.transport = "http"
+		r.logger.Println("Using Streamable HTTP transport")
+		r.flushLog()
+		if response != nil {
+			r.sendToClient(response)
+		}
+		r.runHTTP(ctx, stdinChan, stdinErrChan)
+		return
+	}
+
+	r.logger.Printf("Server returned HTTP %d to POST, falling back to HTTP+SSE transport", status)
+	if err := r.setupSSE(endpoint); err != nil {
+		r.logger.Println(err.Error())
+		r.sendClientError(err.Error())
+		r.flushLog()
+		return
+	}
+
+	// A session ID issued with the rejection is not used by the legacy transport
+	if r.data.GetSessionID() != "" {
+		r.data.SetSessionID("")
+	}
+	r.transport = "sse"
+	r.logger.Println("Using HTTP+SSE transport")
+	r.flushLog()
+
+	// The first message is sent again once the SSE endpoint is known
+	r.runSSE(ctx, stdinChan, stdinErrChan, []string{line})
+}
@@ -0,0 +1,190 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// responseCollector matches messages from the server to the client's request or batch
+// Responses to a single request are forwarded as they arrive. Responses to a batch are
+// held back and re-emitted together as a batch once all of them have arrived.
+type responseCollector struct {
+	batch     bool              // the client sent a batch
+	ids       []string          // id keys of the requests, in the order sent
+	methods   map[string]string // id key -> method of the request
+	pending   map[string]bool   // id keys of requests still awaiting a response
+	collected []json.RawMessage // responses held back for a batch, appended by deliver
+}
+
+// newResponseCollector creates a collector for the requests among msgs
+func newResponseCollector(msgs []map[string]interface{}, batch bool) *responseCollector {
+	c := &responseCollector{batch: batch, methods: make(map[string]string), pending: make(map[string]bool)}
+	for _, jsonMsg := range msgs {
+		if classify(jsonMsg) == kindRequest {
+			key := idKey(jsonMsg["id"])
+			c.ids = append(c.ids, key)
+			c.methods[key] = methodOf(jsonMsg)
+			c.pending[key] = true
+		}
+	}
+	return c
+}
+
+// key identifies the requests, e.g. for tracking the event IDs of their response stream
+func (c *responseCollector) key() string {
+	return strings.Join(c.ids, ",")
+}
+
+// done returns true once every request has received a response
+func (c *responseCollector) done() bool {
+	return len(c.pending) == 0
+}
+
+// take marks the request answered if jsonMsg is the response to a pending request
+// It returns the method of the request and true if so.
+func (c *responseCollector) take(jsonMsg map[string]interface{}) (string, bool) {
+	if classify(jsonMsg) != kindResponse {
+		return "", false
+	}
+
+	key := idKey(jsonMsg["id"])
+	if !c.pending[key] {
+		return "", false
+	}
+	delete(c.pending, key)
+	return c.methods[key], true
+}
+
+// pendingIDs returns the ids of the requests still awaiting a response
+func (c *responseCollector) pendingIDs() []interface{} {
+	var ids []interface{}
+	for _, key := range c.ids {
+		if c.pending[key] {
+			var id interface{}
+			_ = json.Unmarshal([]byte(key), &id)
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// fail returns an error response for every request still pending
+// For a batch, the responses collected so far are included.
+func (c *responseCollector) fail(code int, message string) []byte {
+	var responses []json.RawMessage
+	responses = append(responses, c.collected...)
+	for _, id := range c.pendingIDs() {
+		errResp, _ := json.Marshal(newErrorResponse(id, code, message))
+		responses = append(responses, errResp)
+	}
+	c.pending = make(map[string]bool)
+
+	if c.batch {
+		batch, _ := json.Marshal(responses)
+		return batch
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses[0]
+}
+
+// deliver forwards a message or array of messages from the server to the client
+// Awaited responses to a batch are held back until the whole batch can be sent.
+// Any other array is split into individual messages. c may be nil for messages that
+// are not associated with a request.
+func (r *Relay) deliver(data []byte, c *responseCollector) {
+	for _, msg := range splitMessages(data) {
+		var jsonMsg map[string]interface{}
+		_ = json.Unmarshal(msg, &jsonMsg)
+
+		// Progress keeps the request it refers to from timing out
+		r.inflight.progress(jsonMsg)
+
+		var method string
+		var awaited bool
+		if c != nil {
+			method, awaited = c.take(jsonMsg)
+		}
+		if awaited {
+			r.observeResponse(method, jsonMsg)
+		}
+
+		// The client has given up on cancelled requests, so their responses are dropped
+		if r.inflight.suppress(jsonMsg) {
+			if r.debug {
+				r.logger.Println("Suppressed response to cancelled request:", string(msg))
+			}
+			continue
+		}
+
+		if awaited && c.batch {
+			c.collected = append(c.collected, json.RawMessage(msg))
+			continue
+		}
+		r.sendToClient(msg)
+	}
+
+	if c != nil && c.batch && c.done() && len(c.collected) > 0 {
+		batch, _ := json.Marshal(c.collected)
+		c.collected = nil
+		r.sendToClient(batch)
+	}
+}
+
+// splitMessages splits a JSON array into its elements
+// Each message is compacted onto a single line for stdout.
+func splitMessages(data []byte) [][]byte {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("[")) {
+		return [][]byte{compactMessage(data)}
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return [][]byte{compactMessage(data)}
+	}
+
+	msgs := make([][]byte, 0, len(elements))
+	for _, element := range elements {
+		msgs = append(msgs, compactMessage(element))
+	}
+	return msgs
+}
+
+// compactMessage returns msg on a single line
+// Messages that aren't valid JSON are returned unchanged.
+func compactMessage(msg []byte) []byte {
+	if bytes.IndexAny(msg, "\r\n") < 0 {
+		return msg
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, msg); err != nil {
+		return msg
+	}
+	return buf.Bytes()
+}
+
+// observeResponse inspects the response to one of the client's requests
+// Information the relay needs about the session is recorded here.
+func (r *Relay) observeResponse(method string, jsonMsg map[string]interface{}) {
+	switch method {
+	case "initialize":
+		// Later requests must carry the negotiated protocol version
+		result, _ := jsonMsg["result"].(map[string]interface{})
+		if version, ok := result["protocolVersion"].(string); ok && version != "" {
+			r.data.SetProtocolVersion(version)
+		}
+	case "tools/list":
+		// Tool annotations determine which tool calls are safe to retry
+		if result, ok := jsonMsg["result"].(map[string]interface{}); ok {
+			r.tools.record(result)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Streams for which the last event ID is tracked
+const (
+	streamLegacy = "sse"   // legacy SSE transport stream
+	streamGET    = "get"   // standalone GET stream in HTTP mode
+	streamPOST   = "post:" // prefix for POST response streams, followed by the request id
+)
+
+// sseLineOverhead allows for the field name and line ending when limiting the length of a line
+const sseLineOverhead = 16
+
+// sseEvent is a single dispatched Server-Sent Event
+type sseEvent struct {
+	id    string // value of the last id: field, if any
+	event string // event type, empty means "message"
+	data  string // data: lines joined with newlines
+}
+
+// sseReader parses a text/event-stream body into events
+// See https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+type sseReader struct {
+	reader  *bufio.Reader
+	maxSize int64 // maximum size of the data of an event, 0 for no limit
+}
+
+func newSSEReader(r io.Reader, maxSize int64) *sseReader {
+	return &sseReader{reader: bufio.NewReader(r), maxSize: maxSize}
+}
+
+// Next returns the next complete event
+// Events without data (e.g. keep-alive comments) are skipped. At the end of the
+// stream io.EOF is returned; a partially received event is discarded.
+// An event with more data than the maximum size is discarded as it is read and
+// errMessageTooLarge is returned along with its id and type; the reader remains usable.
+func (s *sseReader) Next() (*sseEvent, error) {
+	ev := &sseEvent{}
+	var data strings.Builder
+	var hasData, tooLarge bool
+
+	for {
+		line, truncated, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if truncated {
+			tooLarge = true
+			continue
+		}
+
+		// Lines may end in CRLF, LF or CR
+		line = strings.TrimRight(line, "\r\n")
+
+		// A blank line dispatches the event
+		if line == "" {
+			if tooLarge {
+				return ev, errMessageTooLarge
+			}
+			if hasData {
+				ev.data = data.String()
+				return ev, nil
+			}
+			// Nothing to dispatch, reset the event type and keep reading
+			ev.event = ""
+			continue
+		}
+
+		// Lines starting with a colon are comments
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		// Split into field and value, removing a single leading space from the value
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "data":
+			if tooLarge || (s.maxSize > 0 && int64(data.Len()+len(value)) > s.maxSize) {
+				tooLarge = true
+				data.Reset()
+				continue
+			}
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "event":
+			ev.event = value
+		case "id":
+			// Per spec, ids containing NULL are ignored
+			if !strings.ContainsRune(value, 0) {
+				ev.id = value
+			}
+		default:
+			// retry: and unknown fields are ignored
+		}
+	}
+}
+
+// readLine reads the next line, including its line ending
+// Anything beyond the maximum size is discarded and reported as truncated, so that a
+// single huge line cannot exhaust memory.
+func (s *sseReader) readLine() (string, bool, error) {
+	var line []byte
+	var truncated bool
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		if s.maxSize > 0 && int64(len(line)+len(chunk)) > s.maxSize+sseLineOverhead {
+			truncated = true
+		} else {
+			line = append(line, chunk...)
+		}
+
+		// The line continues beyond the buffer
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if truncated {
+			return "", true, nil
+		}
+		return string(line), false, nil
+	}
+}
@@ -0,0 +1,64 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSSEReaderNext(t *testing.T) {
+	stream := ": keep-alive\n\n" +
+		"id: 1\ndata: {\"a\":1}\n\n" +
+		"event: message\r\nid: 2\r\ndata: {\"b\":\r\ndata:2}\r\n\r\n" +
+		"retry: 1000\nunknown: x\ndata: {\"c\":3}\n\n" +
+		"data: partial"
+
+	reader := newSSEReader(strings.NewReader(stream), 0)
+	want := []sseEvent{
+		{id: "1", data: `{"a":1}`},
+		{id: "2", event: "message", data: "{\"b\":\n2}"},
+		{data: `{"c":3}`},
+	}
+	for i, expected := range want {
+		ev, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Event %d: %s", i, err)
+		}
+		if *ev != expected {
+			t.Errorf("Event %d = %+v, want %+v", i, *ev, expected)
+		}
+	}
+
+	// The incomplete event at the end of the stream is discarded
+	if ev, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %+v, %v", ev, err)
+	}
+}
+
+func TestSSEReaderMaxSize(t *testing.T) {
+	stream := "id: 1\ndata: " + strings.Repeat("x", 100) + "\n\n" +
+		"id: 2\ndata: " + strings.Repeat("y", 30) + "\ndata: " + strings.Repeat("y", 30) + "\n\n" +
+		"id: 3\ndata: {}\n\n"
+
+	reader := newSSEReader(strings.NewReader(stream), 50)
+	for _, id := range []string{"1", "2"} {
+		ev, err := reader.Next()
+		if err != errMessageTooLarge {
+			t.Fatalf("Expected errMessageTooLarge for event %s, got %v", id, err)
+		}
+		if ev.id != id {
+			t.Errorf("Expected id %s, got %s", id, ev.id)
+		}
+	}
+
+	// The reader remains usable after an oversize event
+	ev, err := reader.Next()
+	if err != nil || ev.id != "3" || ev.data != "{}" {
+		t.Errorf("Expected event 3, got %+v, %v", ev, err)
+	}
+}
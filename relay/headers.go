@@ -0,0 +1,106 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envReference matches ${NAME} in header values
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ParseHeader parses a header given as Name=value, as with the -header option
+func ParseHeader(s string) (string, string, error) {
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("Invalid header '%s': must be Name=value", s)
+	}
+	return name, value, nil
+}
+
+// ReadHeadersFile reads headers from a file
+// The file contains either a JSON object like -headers, or one "Name: value" header per
+// line. Blank lines and lines starting with # are ignored.
+func ReadHeadersFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read headers file: %s", err.Error())
+	}
+
+	headers := make(map[string]string)
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		if err = json.Unmarshal(content, &headers); err != nil {
+			return nil, fmt.Errorf("Failed to parse headers file %s: %s", path, err.Error())
+		}
+		return headers, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("Invalid header in %s line %d: must be Name: value", path, lineNo)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// loadHeaders returns the headers to send with every request
+// Headers from the file are overridden by those in the configuration. ${NAME} in a value
+// is replaced by the environment variable NAME. The values of sensitive headers are redacted
+// from the log, as are variables whose names suggest a secret (e.g. API_TOKEN) wherever
+// they are used.
+func (r *Relay) loadHeaders(file string, configured map[string]string) (map[string]string, error) {
+	headers := make(map[string]string)
+	if file != "" {
+		fromFile, err := ReadHeadersFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range fromFile {
+			headers[name] = value
+		}
+	}
+	for name, value := range configured {
+		headers[name] = value
+	}
+
+	for name, value := range headers {
+		var missing string
+		expanded := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+			variable := ref[2 : len(ref)-1]
+			env, ok := os.LookupEnv(variable)
+			if !ok {
+				missing = variable
+			}
+			if isSensitiveHeader(variable) {
+				r.redactor.add(env)
+			}
+			return env
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("Environment variable %s used in header %s is not set", missing, name)
+		}
+		if isSensitiveHeader(name) {
+			r.redactor.addHeader(expanded)
+		}
+		headers[name] = expanded
+	}
+	return headers, nil
+}
@@ -0,0 +1,48 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"testing"
+)
+
+func TestLoadHeadersRedaction(t *testing.T) {
+	t.Setenv("APP_ENV", "prod")
+	t.Setenv("SERVICE_TOKEN", "tok-1234")
+	t.Setenv("TENANT", "acme-tenant")
+	t.Setenv("PASSWORD_FILE_VALUE", "hunter22")
+
+	r := &Relay{redactor: newRedactor(nil)}
+	headers, err := r.loadHeaders("", map[string]string{
+		"X-Env":         "${APP_ENV}",
+		"X-Tenant":      "${TENANT}/${SERVICE_TOKEN}",
+		"Authorization": "Basic ${TENANT}",
+		"X-Other":       "${PASSWORD_FILE_VALUE}",
+	})
+	if err != nil {
+		t.Fatalf("loadHeaders: %s", err)
+	}
+	if headers["X-Env"] != "prod" || headers["X-Tenant"] != "acme-tenant/tok-1234" {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		// Variables used in ordinary headers are only redacted if their names suggest a secret
+		{"deployed to prod", "deployed to prod"},
+		{"token tok-1234", "token [REDACTED]"},
+		{"password hunter22", "password [REDACTED]"},
+		// The values of sensitive headers are always redacted
+		{"tenant acme-tenant", "tenant [REDACTED]"},
+	}
+	for _, test := range tests {
+		if got := r.redactor.redact(test.in); got != test.want {
+			t.Errorf("redact(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
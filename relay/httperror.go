@@ -0,0 +1,79 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxErrorBody limits how much of an error response body is passed to the client
+const maxErrorBody = 2048
+
+// errorHeaders are response headers that help explain an upstream failure
+var errorHeaders = []string{
+	"WWW-Authenticate",
+	"Retry-After",
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+}
+
+// httpError maps a non-2xx response to a JSON-RPC error code, message and error.data object
+// The response body is consumed.
+func httpError(resp *http.Response) (int, string, map[string]interface{}) {
+	status := resp.StatusCode
+
+	var code int
+	var message string
+	switch {
+	case status == http.StatusUnauthorized:
+		code, message = codeUnauthorized, "Authentication failed"
+	case status == http.StatusForbidden:
+		code, message = codeForbidden, "Access forbidden"
+	case status == http.StatusTooManyRequests:
+		code, message = codeRateLimited, "Rate limited"
+	case status == http.StatusRequestEntityTooLarge:
+		code, message = codePayloadTooLarge, "Request too large"
+	case isTransientStatus(status):
+		code, message = codeUpstreamUnavailable, "Upstream server unavailable"
+	default:
+		code, message = codeUpstreamError, "Upstream request failed"
+	}
+	message = fmt.Sprintf("%s: server returned HTTP %d", message, status)
+	if text := http.StatusText(status); text != "" {
+		message = fmt.Sprintf("%s %s", message, text)
+	}
+
+	data := map[string]interface{}{
+		"status": status,
+	}
+
+	headers := map[string]interface{}{}
+	for _, name := range errorHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	if len(headers) > 0 {
+		data["headers"] = headers
+	}
+
+	// Include the start of the body, which often says what went wrong
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody+1))
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+		data["truncated"] = true
+	}
+	if text := strings.TrimSpace(strings.ToValidUTF8(string(body), string(utf8.RuneError))); text != "" {
+		data["body"] = text
+	}
+
+	return code, message, data
+}
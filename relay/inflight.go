@@ -0,0 +1,168 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"sync"
+)
+
+// inflight tracks the client's requests that are awaiting a response
+// It allows a request to be aborted when the client cancels it, and suppresses any
+// response to a cancelled request that arrives afterwards.
+type inflight struct {
+	mutex     sync.Mutex
+	requests  map[string]context.CancelCauseFunc // id key -> cancels the request's POST (may be nil)
+	cancelled map[string]bool                    // id keys cancelled by the client
+	deadlines map[string]*deadline               // progress token key -> deadline extended on progress
+}
+
+func newInflight() *inflight {
+	return &inflight{
+		requests:  make(map[string]context.CancelCauseFunc),
+		cancelled: make(map[string]bool),
+		deadlines: make(map[string]*deadline),
+	}
+}
+
+// add registers a request, or updates the registration made while it was queued
+// cancel may be nil if the request can't be aborted on its own (e.g. it is part of a batch)
+// It returns true if the client has already cancelled the request, which must not be sent.
+func (f *inflight) add(key string, cancel context.CancelCauseFunc) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, queued := f.requests[key]; !queued {
+		// A cancellation of an earlier request with the same id doesn't apply to this one
+		delete(f.cancelled, key)
+	}
+	f.requests[key] = cancel
+	return f.cancelled[key]
+}
+
+// queue registers the requests in a line from the client before they wait for the pool,
+// so that a cancellation received in the meantime is not lost
+// It returns the id keys of the requests, which forwardHTTP registers again once it runs.
+func (f *inflight) queue(line string) []string {
+	msgs, _, err := parseLine(line)
+	if err != nil {
+		return nil
+	}
+
+	var keys []string
+	for _, jsonMsg := range msgs {
+		if classify(jsonMsg) == kindRequest {
+			key := idKey(jsonMsg["id"])
+			f.add(key, nil)
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// done removes a request once it has completed or been aborted
+func (f *inflight) done(key string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.requests, key)
+	delete(f.cancelled, key)
+}
+
+// cancel marks a request as cancelled and aborts it if possible
+// It returns true if the request was in flight.
+func (f *inflight) cancel(key string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.cancelled[key] = true
+	cancelFunc, ok := f.requests[key]
+	if cancelFunc != nil {
+		cancelFunc(errClientCancelled)
+	}
+	return ok
+}
+
+// isCancelled returns true if the client has cancelled the request
+func (f *inflight) isCancelled(key string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.cancelled[key]
+}
+
+// suppress returns true if jsonMsg is a response to a cancelled request and must not reach the client
+func (f *inflight) suppress(jsonMsg map[string]interface{}) bool {
+	if classify(jsonMsg) != kindResponse {
+		return false
+	}
+
+	key := idKey(jsonMsg["id"])
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !f.cancelled[key] {
+		return false
+	}
+
+	// Only one response is expected, so forget the request unless its POST is still being processed
+	if _, ok := f.requests[key]; !ok {
+		delete(f.cancelled, key)
+	}
+	return true
+}
+
+// watchProgress extends a deadline whenever progress is reported for the token
+func (f *inflight) watchProgress(token string, d *deadline) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.deadlines[token] = d
+}
+
+// unwatchProgress stops tracking progress for the token
+func (f *inflight) unwatchProgress(token string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.deadlines, token)
+}
+
+// progress extends the deadline of the request a notifications/progress message refers to
+func (f *inflight) progress(jsonMsg map[string]interface{}) {
+	if methodOf(jsonMsg) != "notifications/progress" {
+		return
+	}
+	token, ok := progressToken(jsonMsg)
+	if !ok {
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if d, ok := f.deadlines[token]; ok {
+		d.extend()
+	}
+}
+
+// cancelledRequest returns the id key of the request cancelled by a notifications/cancelled message
+func cancelledRequest(jsonMsg map[string]interface{}) (string, bool) {
+	if methodOf(jsonMsg) != "notifications/cancelled" {
+		return "", false
+	}
+	params, _ := jsonMsg["params"].(map[string]interface{})
+	requestID, ok := params["requestId"]
+	if !ok {
+		return "", false
+	}
+	return idKey(requestID), true
+}
+
+// handleCancellations applies any notifications/cancelled messages from the client
+func (r *Relay) handleCancellations(msgs []map[string]interface{}) {
+	for _, jsonMsg := range msgs {
+		if key, ok := cancelledRequest(jsonMsg); ok {
+			if r.inflight.cancel(key) {
+				r.logger.Printf("Client cancelled request %s, aborting", key)
+			} else if r.debug {
+				r.logger.Printf("Client cancelled request %s", key)
+			}
+		}
+	}
+}
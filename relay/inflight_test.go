@@ -0,0 +1,91 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCancelQueuedRequest checks that a request cancelled while waiting for the pool is
+// never sent to the server
+func TestCancelQueuedRequest(t *testing.T) {
+	var mutex sync.Mutex
+	var posted []string
+	received := make(chan struct{})
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		var jsonMsg map[string]interface{}
+		_ = json.Unmarshal(body, &jsonMsg)
+
+		mutex.Lock()
+		posted = append(posted, string(body))
+		mutex.Unlock()
+
+		id, ok := jsonMsg["id"]
+		if !ok {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if id == float64(1) {
+			close(received)
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		response, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "result": map[string]interface{}{}})
+		_, _ = w.Write(response)
+	}))
+	defer server.Close()
+
+	r := newTestRelay(t, Config{Endpoint: server.URL, Concurrency: 1})
+	stdinChan := make(chan string)
+	stdinErrChan := make(chan error)
+
+	output := captureStdout(t, func() {
+		done := make(chan struct{})
+		go func() {
+			r.runHTTP(context.Background(), stdinChan, stdinErrChan)
+			close(done)
+		}()
+
+		// Request 2 waits for the pool while request 1 is being processed
+		stdinChan <- `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow"}}`
+		<-received
+		stdinChan <- `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"fast"}}`
+		stdinChan <- `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":2}}`
+		close(release)
+
+		stdinErrChan <- io.EOF
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Relay did not exit")
+		}
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, body := range posted {
+		if strings.Contains(body, `"id":2`) {
+			t.Errorf("Cancelled request was sent to the server: %s", body)
+		}
+	}
+	if strings.Contains(output, `"id":2`) {
+		t.Errorf("Response to cancelled request written to stdout: %s", output)
+	}
+	if !strings.Contains(output, `"id":1`) {
+		t.Errorf("Response to request 1 missing from stdout: %s", output)
+	}
+}
@@ -0,0 +1,205 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	listenMinBackoff = 1 * time.Second  // first delay before reconnecting the GET stream
+	listenMaxBackoff = 60 * time.Second // upper bound for the reconnect delay
+	resumeAttempts   = 3                // attempts to resume an interrupted POST response stream
+)
+
+// markInitialized signals that the MCP session has been initialized
+// The standalone GET stream is only opened after this point
+func (r *Relay) markInitialized() {
+	r.initOnce.Do(func() {
+		close(r.initialized)
+	})
+}
+
+// httpListener maintains the optional standalone GET stream in HTTP mode
+// Streamable HTTP servers use it to send notifications and requests that are not
+// associated with a client request, e.g. notifications/tools/list_changed or sampling/createMessage
+func (r *Relay) httpListener(ctx context.Context) {
+	// Wait for the session to be established
+	select {
+	case <-ctx.Done():
+		return
+	case <-r.initialized:
+	}
+
+	backoff := listenMinBackoff
+	for {
+		connected, retry := r.listenOnce(ctx)
+		if !retry {
+			return
+		}
+
+		// A stream that was established and then closed is reopened promptly
+		if connected {
+			backoff = listenMinBackoff
+		}
+
+		r.logger.Printf("GET stream closed, reconnecting in %s", backoff)
+		r.flushLog()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > listenMaxBackoff {
+			backoff = listenMaxBackoff
+		}
+	}
+}
+
+// listenOnce opens the GET stream and forwards its events to the client until it closes
+// It returns whether the stream was established and whether the caller should reconnect
+func (r *Relay) listenOnce(ctx context.Context) (connected bool, retry bool) {
+	getURL := r.data.GetPostURL()
+
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		var err error
+		resp, err = r.openEventStream(ctx, streamGET)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, false
+			}
+			r.logger.Printf("Failed to open GET stream: %s", err.Error())
+			r.flushLog()
+			return false, true
+		}
+
+		// Retry once straight away when a new access token has been obtained
+		// If the server rejects that one too, the stream is reopened with the usual backoff.
+		if attempt > 0 || !r.reauthorize(ctx, resp) {
+			break
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	defer resp.Body.Close()
+
+	if r.debug {
+		r.logger.Printf("GET %s -> HTTP %d", getURL, resp.StatusCode)
+	}
+
+	// Servers that don't offer a standalone stream respond with 405
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		r.logger.Println("Server does not offer a GET stream for server-initiated messages")
+		r.flushLog()
+		return false, false
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		r.logger.Printf("GET stream: server returned HTTP %d", resp.StatusCode)
+		r.flushLog()
+		return false, true
+	}
+
+	if !isEventStream(resp) {
+		r.logger.Printf("GET stream: unexpected Content-Type %s", resp.Header.Get("Content-Type"))
+		r.flushLog()
+		return false, true
+	}
+
+	r.logger.Printf("Opened GET stream at %s", getURL)
+	r.flushLog()
+
+	r.relayEventStream(resp.Body, streamGET, nil)
+	if ctx.Err() != nil {
+		return true, false
+	}
+	return true, true
+}
+
+// openEventStream sends a GET for an SSE stream on the Streamable HTTP endpoint
+// If events have been received on the stream before, Last-Event-ID asks the server to
+// replay anything sent after that event.
+func (r *Relay) openEventStream(ctx context.Context, stream string) (*http.Response, error) {
+	req, err := r.newHTTPRequest(ctx, "GET", r.data.GetPostURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	if lastID := r.data.GetLastEventID(stream); lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+		if r.debug {
+			r.logger.Printf("Resuming %s stream after event %s", stream, lastID)
+		}
+	}
+
+	return r.httpClient.Do(req)
+}
+
+// resumeEventStream resumes a POST response stream that closed before every response arrived
+// This is only possible if the server assigned event IDs to the stream.
+func (r *Relay) resumeEventStream(ctx context.Context, stream string, collector *responseCollector, timeout time.Duration) []byte {
+	for attempt := 1; attempt <= resumeAttempts; attempt++ {
+		lastID := r.data.GetLastEventID(stream)
+		if lastID == "" {
+			break
+		}
+
+		r.logger.Printf("Response stream for request %s closed early, resuming after event %s (attempt %d)", collector.key(), lastID, attempt)
+		r.flushLog()
+		select {
+		case <-ctx.Done():
+			return r.requestAborted(ctx, collector, timeout)
+		case <-time.After(time.Duration(attempt) * listenMinBackoff):
+		}
+
+		resp, err := r.openEventStream(ctx, stream)
+		if ctx.Err() != nil {
+			if resp != nil {
+				_ = resp.Body.Close()
+			}
+			return r.requestAborted(ctx, collector, timeout)
+		}
+		if err != nil {
+			r.logger.Printf("Failed to resume response stream: %s", err.Error())
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 || !isEventStream(resp) {
+			r.logger.Printf("Failed to resume response stream: server returned HTTP %d", resp.StatusCode)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+
+			// The server does not support resumption
+			if resp.StatusCode == http.StatusMethodNotAllowed {
+				break
+			}
+			continue
+		}
+
+		done := r.relayEventStream(resp.Body, stream, collector)
+		_ = resp.Body.Close()
+		if done {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return r.requestAborted(ctx, collector, timeout)
+		}
+	}
+
+	msg := fmt.Sprintf("Response stream for request %s closed before a response was received", collector.key())
+	r.logger.Println(msg)
+	r.flushLog()
+	return collector.fail(codeInternalError, msg)
+}
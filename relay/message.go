@@ -0,0 +1,174 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// JSON-RPC error codes returned by the relay
+const (
+	codeInvalidRequest = -32600
+	codeInternalError  = -32603
+	codeRequestTimeout = -32001 // same code as the MCP SDKs use for request timeouts
+
+	// Upstream HTTP failures, in the implementation-defined server error range
+	codeUpstreamError       = -32010 // any other non-2xx status
+	codeUnauthorized        = -32011 // 401
+	codeForbidden           = -32012 // 403
+	codeRateLimited         = -32013 // 429
+	codePayloadTooLarge     = -32014 // 413
+	codeUpstreamUnavailable = -32015 // 502, 503, 504 or the server could not be reached
+	codeResponseTooLarge    = -32016 // the response exceeds the maximum message size
+)
+
+// messageKind identifies the type of a JSON-RPC message
+type messageKind int
+
+const (
+	kindInvalid      messageKind = iota // neither a request, notification nor response
+	kindRequest                         // has a method and an id
+	kindNotification                    // has a method but no id
+	kindResponse                        // has an id and a result or error, but no method
+)
+
+func (k messageKind) String() string {
+	switch k {
+	case kindRequest:
+		return "request"
+	case kindNotification:
+		return "notification"
+	case kindResponse:
+		return "response"
+	default:
+		return "invalid"
+	}
+}
+
+// classify returns the kind of a parsed JSON-RPC message
+func classify(jsonMsg map[string]interface{}) messageKind {
+	_, hasID := jsonMsg["id"]
+	_, hasMethod := jsonMsg["method"]
+	_, hasResult := jsonMsg["result"]
+	_, hasError := jsonMsg["error"]
+
+	switch {
+	case hasMethod && hasID:
+		return kindRequest
+	case hasMethod:
+		return kindNotification
+	case hasID && (hasResult || hasError):
+		return kindResponse
+	default:
+		return kindInvalid
+	}
+}
+
+// methodOf returns the method of a JSON-RPC message, or an empty string for responses
+func methodOf(jsonMsg map[string]interface{}) string {
+	method, _ := jsonMsg["method"].(string)
+	return method
+}
+
+// idKey returns a canonical string form of a JSON-RPC id for use as a map key
+func idKey(id interface{}) string {
+	key, _ := json.Marshal(id)
+	return string(key)
+}
+
+// errUnexpectedInput is returned for lines that are neither a JSON object nor a batch
+var errUnexpectedInput = errors.New("unexpected input")
+
+// parseLine parses a line from the client as a single JSON-RPC message or a batch
+func parseLine(line string) ([]map[string]interface{}, bool, error) {
+	line = strings.TrimSpace(line)
+	switch {
+	case strings.HasPrefix(line, "{"):
+		var jsonMsg map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &jsonMsg); err != nil {
+			return nil, false, err
+		}
+		return []map[string]interface{}{jsonMsg}, false, nil
+	case strings.HasPrefix(line, "["):
+		var batch []map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &batch); err != nil {
+			return nil, true, err
+		}
+		return batch, true, nil
+	default:
+		return nil, false, errUnexpectedInput
+	}
+}
+
+// classifyAll returns the kind of a message or batch
+// A batch containing any request is a request since the client expects a reply.
+// A batch of responses is a response; other batches without requests are notifications.
+func classifyAll(msgs []map[string]interface{}) messageKind {
+	if len(msgs) == 0 {
+		return kindInvalid
+	}
+
+	kind := kindResponse
+	for _, jsonMsg := range msgs {
+		switch classify(jsonMsg) {
+		case kindRequest:
+			return kindRequest
+		case kindNotification:
+			kind = kindNotification
+		case kindInvalid:
+			return kindInvalid
+		}
+	}
+	return kind
+}
+
+// classifyLine parses a line from the client and returns its kind
+func classifyLine(line string) messageKind {
+	msgs, _, err := parseLine(line)
+	if err != nil {
+		return kindInvalid
+	}
+	return classifyAll(msgs)
+}
+
+// forwardClientResponse POSTs the client's reply to a server-initiated request
+// (e.g. roots/list, sampling/createMessage or elicitation/create)
+// The server acknowledges it with 202 Accepted. Any body is discarded and never echoed to the client.
+func (r *Relay) forwardClientResponse(line string) {
+	if r.debug {
+		r.logger.Println("C->S (response):", line)
+	}
+
+	// Responses are forwarded on the stdin loop, so a server that doesn't accept one must not stall it
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	resp, _, err := r.postMessage(ctx, line)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to forward response: %s", err.Error())
+		r.logger.Println(msg)
+		r.flushLog()
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		r.logger.Printf("Expected HTTP 202 for response, server returned HTTP %d", resp.StatusCode)
+		if r.debug {
+			if respBody, _ := io.ReadAll(resp.Body); len(respBody) > 0 {
+				r.logger.Printf("Server response to forwarded response (discarded): %s", string(respBody))
+			}
+		}
+		r.flushLog()
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+}
@@ -0,0 +1,117 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secrets in the log
+const redacted = "[REDACTED]"
+
+// minSecretLength is the length below which values are not redacted, as they would
+// match too much of the log
+const minSecretLength = 4
+
+// sensitiveHeaders are headers whose values are always redacted from the log
+var sensitiveHeaders = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+	"api-key",
+	"x-auth-token",
+}
+
+// sensitiveWords identify other headers that probably hold credentials
+var sensitiveWords = []string{"token", "secret", "password", "apikey", "api-key", "api_key"}
+
+// sensitivePattern matches sensitive headers and OAuth parameters in logged text, such as
+// "Authorization: Bearer ..." or {"access_token":"..."}, and bearer tokens on their own
+var sensitivePattern = regexp.MustCompile(`(?i)(\b(?:` + strings.Join(sensitiveHeaders, "|") +
+	`|access_token|refresh_token|id_token|client_secret|client_assertion|password)"?\s*[:=]\s*"?)[^"\r\n&]+|(\bBearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+
+// challengeParameter matches what sensitivePattern takes for a bearer token in a challenge
+// such as WWW-Authenticate: Bearer error="invalid_token", which must be left alone
+var challengeParameter = regexp.MustCompile(`(?i)^Bearer\s+[A-Za-z][A-Za-z0-9_\-]{0,23}=$`)
+
+// isSensitiveHeader returns true if the value of the header should not be logged
+// It is also used for the names of environment variables referenced in header values.
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	if slices.Contains(sensitiveHeaders, name) {
+		return true
+	}
+	for _, word := range sensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactor is a log writer that removes credentials before they reach the log
+// Besides the patterns above, it removes every value registered as a secret, such as
+// header values and access tokens, wherever they appear.
+type redactor struct {
+	w       io.Writer
+	mutex   sync.RWMutex
+	secrets map[string]bool
+}
+
+func newRedactor(w io.Writer) *redactor {
+	return &redactor{w: w, secrets: make(map[string]bool)}
+}
+
+// add registers a secret
+func (r *redactor) add(secret string) {
+	secret = strings.TrimSpace(secret)
+	if r == nil || len(secret) < minSecretLength {
+		return
+	}
+	r.mutex.Lock()
+	r.secrets[secret] = true
+	r.mutex.Unlock()
+}
+
+// addHeader registers the value of a sensitive header
+// The credentials are registered separately from the authentication scheme, if any.
+func (r *redactor) addHeader(value string) {
+	r.add(value)
+	if _, credentials, ok := strings.Cut(strings.TrimSpace(value), " "); ok {
+		r.add(credentials)
+	}
+}
+
+// redact returns s with secrets replaced
+func (r *redactor) redact(s string) string {
+	r.mutex.RLock()
+	for secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	r.mutex.RUnlock()
+
+	return sensitivePattern.ReplaceAllStringFunc(s, func(match string) string {
+		if challengeParameter.MatchString(match) {
+			return match
+		}
+		return sensitivePattern.ReplaceAllString(match, "$1$2"+redacted)
+	})
+}
+
+// Write writes p to the underlying writer after redacting it
+// log.Logger calls Write once per message, so patterns never span two calls.
+func (r *redactor) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, r.redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,51 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	r := newRedactor(nil)
+	r.add("s3cr3t-header-value")
+	r.addHeader("Token abcdefgh1234")
+	r.add("abc") // too short to redact
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"X-Custom: s3cr3t-header-value", "X-Custom: [REDACTED]"},
+		{"sent abcdefgh1234 again", "sent [REDACTED] again"},
+		{"Authorization: Token abcdefgh1234", "Authorization: [REDACTED]"},
+		{"Authorization: Basic dXNlcjpwYXNz", "Authorization: [REDACTED]"},
+		{"request with Bearer eyJhbGciOi.eyJzdWIi.c2lnbmF0dXJl", "request with Bearer [REDACTED]"},
+		{`{"access_token":"at-123","token_type":"Bearer"}`, `{"access_token":"[REDACTED]","token_type":"Bearer"}`},
+		{"grant_type=refresh_token&refresh_token=rt-456&client_id=c", "grant_type=refresh_token&refresh_token=[REDACTED]&client_id=c"},
+		{`WWW-Authenticate: Bearer error="invalid_token", scope="mcp"`, `WWW-Authenticate: Bearer error="invalid_token", scope="mcp"`},
+		{"abc and nothing else", "abc and nothing else"},
+	}
+	for _, test := range tests {
+		if got := r.redact(test.in); got != test.want {
+			t.Errorf("redact(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestRedactorWrite(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRedactor(&buf)
+	r.add("password123")
+
+	logger := log.New(r, "", 0)
+	logger.Printf("Connecting with password123")
+	if got := buf.String(); got != "Connecting with [REDACTED]\n" {
+		t.Errorf("Logged %q", got)
+	}
+}
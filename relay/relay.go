@@ -10,53 +10,120 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/PivotLLM/MCPRelay/auth"
 	"github.com/PivotLLM/MCPRelay/data"
 )
 
 // Logger is an alias for log.Logger
 type Logger = *log.Logger
 
+// DefaultConcurrency is the default number of concurrent POSTs in HTTP mode
+const DefaultConcurrency = 8
+
+// Config holds the settings used to create a Relay
+type Config struct {
+	Endpoint    string            // URL of the MCP server (POST endpoint or SSE stream)
+	Transport   string            // "auto", "http" or "sse"
+	Headers     map[string]string // custom HTTP headers sent with every request
+	HeadersFile string            // file with further headers, overridden by Headers
+	Logger      Logger            // logger, may be nil
+	LogFile     *os.File          // log file to sync after important events, may be nil
+	Debug       bool              // log all traffic
+	Concurrency int               // maximum number of in-flight POSTs in HTTP mode
+	UnixSocket  string            // path of a Unix socket to connect to instead of the URL's host, may be empty
+	Proxy       string            // proxy URL (http, https or socks5), empty to use HTTP_PROXY etc.
+	NoProxy     string            // comma-separated hosts that bypass the proxy, in NO_PROXY format
+	TLS         TLSOptions        // client certificate, trusted CAs etc. for https:// URLs
+
+	OAuth             *auth.OAuthConfig             // MCP authorization with OAuth 2.1, nil to disable
+	ClientCredentials *auth.ClientCredentialsConfig // OAuth client credentials grant, nil to disable
+	TokenCommand      string                        // command that prints the bearer token, empty to disable
+
+	// Request timeouts in HTTP mode, zero means no timeout
+	Timeout        time.Duration            // default timeout
+	MethodTimeouts map[string]time.Duration // per-method overrides, e.g. "tools/list"
+	ToolTimeouts   map[string]time.Duration // per-tool overrides for tools/call
+
+	Retry RetryPolicy // retries of transient upstream failures in HTTP mode
+
+	MaxMessageSize int64 // maximum size of a message from the server in bytes, 0 for no limit
+
+	// Reload rebuilds the configuration on SIGHUP or when a watched file changes, nil to
+	// disable reloading. Only headers and credentials are updated without a restart.
+	Reload     func() (Config, error)
+	WatchFiles []string // further files to watch for changes, e.g. the configuration file
+}
+
 type Relay struct {
 	writerMutex sync.Mutex
 	debug       bool
 	logger      Logger
 	logFile     *os.File
+	redactor    *redactor // removes credentials from the log
 	data        *data.Data
-	headers     map[string]string
-	transport   string       // "http" or "sse"
-	httpClient  *http.Client // persistent HTTP client for keep-alive
-	sessionID   string       // MCP session ID for HTTP transport
+	transport   string        // "auto", "http" or "sse"
+	httpClient  *http.Client  // persistent HTTP client for keep-alive
+	authClient  *http.Client  // HTTP client for the authorization server
+	concurrency int           // maximum number of in-flight POSTs in HTTP mode
+	initialized chan struct{} // closed once notifications/initialized has been forwarded
+	initOnce    sync.Once
+	handshake   handshake  // cached initialize exchange for session re-establishment
+	reinitMutex sync.Mutex // serializes session re-establishment
+	inflight    *inflight  // requests awaiting a response
+
+	timeout        time.Duration            // default request timeout
+	methodTimeouts map[string]time.Duration // per-method request timeouts
+	toolTimeouts   map[string]time.Duration // per-tool request timeouts for tools/call
+
+	retry RetryPolicy    // retries of transient upstream failures
+	tools retryableTools // tools annotated as safe to retry
+
+	maxMessageSize int64 // maximum size of a message from the server, 0 for no limit
+
+	serverURL string // URL of the MCP server, without unix://
+
+	// Settings that can be reloaded, see reload.go
+	settingsMutex sync.RWMutex      // guards headers, tokens and config
+	headers       map[string]string // replaced as a whole, never modified
+	tokens        auth.TokenSource  // supplies the Authorization header, nil if not configured
+	config        Config            // configuration in effect, for reloads
 }
 
-func New(endpoint string, logger Logger, logFile *os.File, debug bool, headers map[string]string, transport string) (*Relay, error) {
+func New(cfg Config) (*Relay, error) {
 	var err error
+	endpoint := cfg.Endpoint
 
 	// Instantiate our object
 	r := &Relay{
-		logger:    logger,
-		logFile:   logFile,
-		debug:     debug,
-		data:      data.New(logger),
-		headers:   headers,
-		transport: transport,
-		httpClient: &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns:        10,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
+		logger:         cfg.Logger,
+		logFile:        cfg.LogFile,
+		debug:          cfg.Debug,
+		transport:      cfg.Transport,
+		concurrency:    cfg.Concurrency,
+		initialized:    make(chan struct{}),
+		inflight:       newInflight(),
+		timeout:        cfg.Timeout,
+		methodTimeouts: cfg.MethodTimeouts,
+		toolTimeouts:   cfg.ToolTimeouts,
+		retry:          cfg.Retry,
+		maxMessageSize: cfg.MaxMessageSize,
+	}
+
+	if r.concurrency < 1 {
+		r.concurrency = DefaultConcurrency
 	}
 
 	// Protect against nil logger
@@ -64,34 +131,60 @@ func New(endpoint string, logger Logger, logFile *os.File, debug bool, headers m
 		r.logger = log.New(io.Discard, "", 0)
 	}
 
+	// Everything the relay logs passes through the redactor
+	r.redactor = newRedactor(r.logger.Writer())
+	r.logger = log.New(r.redactor, r.logger.Prefix(), r.logger.Flags())
+
 	// Set up data store
 	r.data = data.New(r.logger)
 
-	// Mode-specific setup
-	if transport == "sse" {
-		// Parse URL for SSE mode
-		var u *url.URL
-		u, err = url.Parse(endpoint)
-		if err != nil {
-			msg := fmt.Sprintf("Error parsing URL '%s': %s", endpoint, err.Error())
-
-			// Advise the MCP client if it is listening
-			r.sendClientError(msg)
+	if r.headers, err = r.loadHeaders(cfg.HeadersFile, cfg.Headers); err != nil {
+		r.sendClientError(err.Error())
+		return &Relay{}, err
+	}
 
-			// Log fatal error
-			return &Relay{}, errors.New(msg)
+	// A unix:// URL names the socket to connect to as well as the HTTP path
+	unixSocket := cfg.UnixSocket
+	if strings.HasPrefix(endpoint, unixScheme) {
+		if unixSocket, endpoint, err = parseUnixURL(endpoint); err != nil {
+			r.sendClientError(err.Error())
+			return &Relay{}, err
 		}
+	}
+	if unixSocket != "" {
+		r.logger.Printf("Connecting to the server over Unix socket %s", unixSocket)
+	}
+	if r.httpClient, err = r.newHTTPClient(cfg, unixSocket); err != nil {
+		r.sendClientError(err.Error())
+		return &Relay{}, err
+	}
+	if r.authClient, err = r.newAuthClient(cfg, unixSocket); err != nil {
+		r.sendClientError(err.Error())
+		return &Relay{}, err
+	}
 
-		// Set the server based on parsing
-		// This will avoid repeated parsing if the SSE server responds with a dynamic endpoint
-		r.data.SetServer(fmt.Sprintf("%s://%s", u.Scheme, u.Host))
+	r.serverURL = endpoint
+	if r.tokens, err = r.newTokenSource(cfg); err != nil {
+		r.sendClientError(err.Error())
+		return &Relay{}, err
+	}
+	r.config = cfg
 
-		// Set the SSE URL as specified by the user
-		r.data.SetSSEURL(endpoint)
+	// Mode-specific setup
+	switch r.transport {
+	case "sse":
+		if err = r.setupSSE(endpoint); err != nil {
+			// Advise the MCP client if it is listening
+			r.sendClientError(err.Error())
 
-		// Set the default POST endpoint for SSE
-		r.data.SetPostPath("/messages")
-	} else {
+			// Log fatal error
+			return &Relay{}, err
+		}
+	case "auto":
+		// Auto mode: URL is tried as a POST endpoint first, see runAuto
+		r.data.SetPostURL(endpoint)
+		r.logger.Printf("Auto mode: probing %s for Streamable HTTP", endpoint)
+	default:
 		// HTTP mode: URL is the POST endpoint directly
 		r.data.SetPostURL(endpoint)
 		r.logger.Printf("HTTP mode: POST endpoint set to %s", endpoint)
@@ -101,6 +194,26 @@ func New(endpoint string, logger Logger, logFile *os.File, debug bool, headers m
 	return r, nil
 }
 
+// setupSSE configures the data store for the legacy HTTP+SSE transport
+func (r *Relay) setupSSE(endpoint string) error {
+	// Parse URL for SSE mode
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("Error parsing URL '%s': %s", endpoint, err.Error())
+	}
+
+	// Set the server based on parsing
+	// This will avoid repeated parsing if the SSE server responds with a dynamic endpoint
+	r.data.SetServer(fmt.Sprintf("%s://%s", u.Scheme, u.Host))
+
+	// Set the SSE URL as specified by the user
+	r.data.SetSSEURL(endpoint)
+
+	// Set the default POST endpoint for SSE
+	r.data.SetPostPath("/messages")
+	return nil
+}
+
 // flushLog syncs the log file to disk if one is configured
 func (r *Relay) flushLog() {
 	if r.logFile != nil {
@@ -109,39 +222,155 @@ func (r *Relay) flushLog() {
 }
 
 func (r *Relay) Run() {
-	if r.transport == "http" {
-		r.runHTTP()
-	} else {
-		r.runSSE()
+	// Shut down cleanly on SIGINT/SIGTERM as well as when the client closes stdin
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Headers and credentials are reloaded on SIGHUP or when a watched file changes
+	go r.watchReload(ctx)
+
+	stdinChan, stdinErrChan := r.readStdin()
+	switch r.transport {
+	case "auto":
+		r.runAuto(ctx, stdinChan, stdinErrChan)
+	case "http":
+		r.runHTTP(ctx, stdinChan, stdinErrChan)
+	default:
+		r.runSSE(ctx, stdinChan, stdinErrChan, nil)
 	}
 }
 
-func (r *Relay) runHTTP() {
-	r.logger.Println("Starting HTTP mode")
+// readStdin reads lines from stdin in the background
+// The first read error (including io.EOF) is sent on the error channel and reading stops
+func (r *Relay) readStdin() (<-chan string, <-chan error) {
+	stdinChan := make(chan string)
+	stdinErrChan := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				stdinErrChan <- err
+				return
+			}
+			stdinChan <- line
+		}
+	}()
+	return stdinChan, stdinErrChan
+}
+
+func (r *Relay) runHTTP(ctx context.Context, stdinChan <-chan string, stdinErrChan <-chan error) {
+	r.logger.Printf("Starting HTTP mode with up to %d concurrent requests", r.concurrency)
 	r.flushLog()
 
-	reader := bufio.NewReader(os.Stdin)
+	// The standalone GET stream runs until the relay exits
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go r.httpListener(ctx)
+
+	// Requests are processed concurrently so that a slow request doesn't stall the rest
+	// The semaphore bounds the number of in-flight POSTs
+	sem := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+
 	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
+		var line string
+		select {
+		case <-ctx.Done():
+			r.logger.Println("Received termination signal, shutting down")
+			r.terminateSession()
+			r.flushLog()
+			return
+		case err := <-stdinErrChan:
 			if err == io.EOF {
 				r.logger.Println("EOF on stdin, client closed connection")
 			} else {
 				r.logger.Printf("stdin error: %s", err.Error())
 			}
+
+			// Allow in-flight requests to complete and deliver their responses
+			wg.Wait()
+			r.terminateSession()
 			r.flushLog()
 			return
+		case line = <-stdinChan:
 		}
 
-		// Process message and get response
-		response := r.processHTTPRequest(line)
-		if response != nil {
-			r.sendToClient(response)
+		// Notifications and responses are acknowledged quickly and are forwarded in order so that,
+		// for example, notifications/initialized reaches the server before later requests
+		if kind := classifyLine(line); kind == kindNotification || kind == kindResponse {
+			r.processHTTPRequest(line)
+			continue
 		}
+
+		// The semaphore is acquired by the goroutine so that stdin is still read while
+		// the pool is busy and e.g. notifications/cancelled is forwarded promptly
+		// The requests are registered first so that they can be cancelled while queued.
+		keys := r.inflight.queue(line)
+		wg.Add(1)
+		go func(line string) {
+			defer wg.Done()
+			defer func() {
+				for _, key := range keys {
+					r.inflight.done(key)
+				}
+			}()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Process message and send the response as soon as it is available
+			// sendToClient serializes writes to stdout
+			response := r.processHTTPRequest(line)
+			if response != nil {
+				r.sendToClient(response)
+			}
+		}(line)
+	}
+}
+
+// newErrorResponse constructs a JSON-RPC 2.0 error response
+func newErrorResponse(id interface{}, code int, message string) map[string]interface{} {
+	return newErrorResponseData(id, code, message, nil)
+}
+
+// newErrorResponseData constructs a JSON-RPC 2.0 error response with an error.data member
+// data is omitted if nil
+func newErrorResponseData(id interface{}, code int, message string, data map[string]interface{}) map[string]interface{} {
+	errObj := map[string]interface{}{
+		"code":    code,
+		"message": message,
+	}
+	if data != nil {
+		errObj["data"] = data
+	}
+	return map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   errObj,
 	}
 }
 
 func (r *Relay) createErrorResponse(requestJSON string, code int, message string) []byte {
+	return r.createErrorResponseData(requestJSON, code, message, nil)
+}
+
+// createErrorResponseData is createErrorResponse with an error.data member
+func (r *Relay) createErrorResponseData(requestJSON string, code int, message string, data map[string]interface{}) []byte {
+	// A batch gets an error response for each request it contains
+	if msgs, batch, err := parseLine(requestJSON); err == nil && batch {
+		errResps := []interface{}{}
+		for _, req := range msgs {
+			if classify(req) == kindRequest {
+				errResps = append(errResps, newErrorResponseData(req["id"], code, message, data))
+			}
+		}
+		if len(errResps) == 0 {
+			errResps = append(errResps, newErrorResponseData(nil, code, message, data))
+		}
+		respBytes, _ := json.Marshal(errResps)
+		return respBytes
+	}
+
 	// Try to extract the id from the request
 	var id interface{} = nil
 	if requestJSON != "" {
@@ -154,124 +383,404 @@ func (r *Relay) createErrorResponse(requestJSON string, code int, message string
 	}
 
 	// Construct proper JSON-RPC 2.0 error response
-	errResp := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      id,
-		"error": map[string]interface{}{
-			"code":    code,
-			"message": message,
-		},
+	respBytes, _ := json.Marshal(newErrorResponseData(id, code, message, data))
+	return respBytes
+}
+
+// newHTTPRequest creates a request to the Streamable HTTP endpoint
+// The session ID and protocol version (if any) and custom headers are added
+func (r *Relay) newHTTPRequest(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
 	}
 
-	respBytes, _ := json.Marshal(errResp)
-	return respBytes
+	// Add session ID as header if we have one (per MCP spec)
+	sessionID := r.data.GetSessionID()
+	if sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", sessionID)
+	}
+
+	// Add the negotiated protocol version once initialization has completed (per MCP spec)
+	if version := r.data.GetProtocolVersion(); version != "" {
+		req.Header.Set("MCP-Protocol-Version", version)
+	}
+
+	// Add custom headers (authentication, etc.)
+	r.addHeaders(req)
+
+	if r.debug && sessionID != "" {
+		r.logger.Printf("Sending %s with session ID header: Mcp-Session-Id: %s", method, sessionID)
+	}
+	return req, nil
+}
+
+// postMessage POSTs a JSON-RPC message to the Streamable HTTP endpoint
+// It returns the response and the session ID that the request was sent with
+func (r *Relay) postMessage(ctx context.Context, line string) (*http.Response, string, error) {
+	postURL := r.data.GetPostURL()
+	req, err := r.newHTTPRequest(ctx, "POST", postURL, strings.NewReader(line))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	sessionID := req.Header.Get("Mcp-Session-Id")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, sessionID, err
+	}
+
+	// Extract session ID from Mcp-Session-Id header (if present)
+	if newID := resp.Header.Get("Mcp-Session-Id"); newID != "" && r.data.GetSessionID() == "" {
+		// Store the full session ID including the "mcp-session-" prefix
+		r.data.SetSessionID(newID)
+		r.logger.Printf("Extracted MCP session ID: %s", newID)
+	}
+
+	if r.debug {
+		r.logger.Printf("POST %s -> HTTP %d", postURL, resp.StatusCode)
+	}
+	return resp, sessionID, nil
 }
 
 func (r *Relay) processHTTPRequest(line string) []byte {
+	return r.forwardHTTP(line, nil)
+}
+
+// forwardHTTP POSTs a line from stdin and returns the output for the client, if any
+// If reject is not nil it is called for a non-2xx status; when it returns true the
+// response is abandoned and nothing is returned to the client (see runAuto).
+func (r *Relay) forwardHTTP(line string, reject func(status int) bool) []byte {
 	// Trim whitespace
 	line = strings.TrimSpace(line)
 
-	// Validate JSON-RPC format
-	if !strings.HasPrefix(line, "{") {
+	// Parse JSON-RPC message or batch
+	msgs, batch, err := parseLine(line)
+	if err == errUnexpectedInput {
 		r.logger.Printf("Unexpected input: %s", line)
 		return nil
 	}
-
-	// Parse JSON
-	var jsonMsg map[string]interface{}
-	if err := json.Unmarshal([]byte(line), &jsonMsg); err != nil {
+	if err != nil {
 		r.logger.Printf("Invalid JSON: %s", err.Error())
 		return nil
 	}
 
-	// Check if this is a notification (no id field)
-	_, hasID := jsonMsg["id"]
-	isNotification := !hasID
+	// An empty batch is invalid and gets a single error response
+	if batch && len(msgs) == 0 {
+		r.logger.Println("Received empty batch")
+		respBytes, _ := json.Marshal(newErrorResponse(nil, codeInvalidRequest, "Invalid Request: empty batch"))
+		return respBytes
+	}
+
+	// Replies to server-initiated requests take a separate path and never produce output
+	kind := classifyAll(msgs)
+	if kind == kindResponse {
+		r.forwardClientResponse(line)
+		return nil
+	}
+	if kind == kindInvalid {
+		r.logger.Printf("Invalid JSON-RPC message: %s", line)
+		return nil
+	}
+
+	// Check if this is a notification (or a batch without requests)
+	notification := kind == kindNotification
 
 	if r.debug {
-		if isNotification {
+		switch {
+		case batch:
+			r.logger.Printf("C->S (batch of %d): %s", len(msgs), line)
+		case notification:
 			r.logger.Println("C->S (notification):", line)
-		} else {
+		default:
 			r.logger.Println("C->S:", line)
 		}
 	}
 
-	// For notifications in HTTP mode, we don't send them to the server
-	// The server doesn't support notifications over HTTP transport
-	if isNotification {
-		if r.debug {
-			r.logger.Println("Skipping notification in HTTP mode (not supported by HTTP transport)")
+	// Remember the handshake so that it can be replayed if the session expires
+	var initialize, initialized bool
+	for _, jsonMsg := range msgs {
+		switch methodOf(jsonMsg) {
+		case "initialize":
+			initialize = true
+			r.handshake.setInitialize(line)
+		case "notifications/initialized":
+			initialized = true
+			r.handshake.setInitialized(line)
 		}
-		return nil
 	}
 
-	// Build POST request
-	postURL := r.data.GetPostURL()
-	req, _ := http.NewRequest("POST", postURL, bytes.NewReader([]byte(line)))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json, text/event-stream")
+	// Cancel any in-flight requests the client has given up on
+	// The notification itself is still forwarded to the server below
+	r.handleCancellations(msgs)
 
-	// Add session ID as header if we have one (per MCP spec)
-	if r.sessionID != "" {
-		req.Header.Set("Mcp-Session-Id", r.sessionID)
-	}
+	// Responses are matched to the request, or to the requests in a batch
+	collector := newResponseCollector(msgs, batch)
 
-	// Add custom headers (authentication, etc.)
-	for key, value := range r.headers {
-		req.Header.Set(key, value)
+	// Each request gets its own context so that it can be aborted if the client cancels it
+	// or it times out. Requests in a batch can't be aborted individually, but late responses
+	// are suppressed.
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	// A notification holds up stdin until the server accepts it, so it is not waited for indefinitely
+	if notification {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, notifyTimeout)
+		defer stop()
 	}
 
-	if r.debug && r.sessionID != "" {
-		r.logger.Printf("Sending request with session ID header: Mcp-Session-Id: %s", r.sessionID)
+	var timeout time.Duration
+	if !notification {
+		var requests, cancelled int
+		for _, jsonMsg := range msgs {
+			if classify(jsonMsg) != kindRequest {
+				continue
+			}
+			key := idKey(jsonMsg["id"])
+			var wasCancelled bool
+			if batch {
+				wasCancelled = r.inflight.add(key, nil)
+			} else {
+				wasCancelled = r.inflight.add(key, cancel)
+			}
+			defer r.inflight.done(key)
+			requests++
+			if wasCancelled {
+				cancelled++
+			}
+		}
+
+		// Nothing is sent if the client cancelled every message while they were queued
+		if requests == len(msgs) && cancelled == requests {
+			r.logger.Printf("Client cancelled request %s before it was sent", collector.key())
+			r.flushLog()
+			return nil
+		}
+
+		// The deadline is extended whenever the server reports progress
+		timeout = r.batchTimeout(msgs)
+		if timeout > 0 {
+			d := newDeadline(timeout, cancel)
+			defer d.stop()
+			ctx = context.WithValue(ctx, deadlineKey{}, d)
+			for _, jsonMsg := range msgs {
+				if token, ok := progressToken(jsonMsg); ok {
+					r.inflight.watchProgress(token, d)
+					defer r.inflight.unwatchProgress(token)
+				}
+			}
+		}
 	}
 
+	// Transient failures are retried for methods that are safe to repeat
+	retryable := !notification && r.isBatchRetryable(msgs)
+
 	// Send request using persistent client for keep-alive
-	resp, err := r.httpClient.Do(req)
+	resp, sessionID, err := r.postWithRetry(ctx, line, retryable)
+	if ctx.Err() != nil {
+		// Cancelled by the client or timed out
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		if notification {
+			r.logger.Printf("Server did not accept notification within %s", notifyTimeout)
+			r.flushLog()
+			return nil
+		}
+		return r.requestAborted(ctx, collector, timeout)
+	}
 	if err != nil {
 		msg := fmt.Sprintf("Failed to POST: %s", err.Error())
 		r.logger.Println(msg)
 		r.flushLog()
-		return r.createErrorResponse(line, -32603, msg)
-	}
-	defer resp.Body.Close()
 
-	// Extract session ID from Mcp-Session-Id header (if present)
-	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" && r.sessionID == "" {
-		// Store the full session ID including the "mcp-session-" prefix
-		r.sessionID = sessionID
-		r.logger.Printf("Extracted MCP session ID: %s", r.sessionID)
+		// There is no id to respond to for a notification
+		if notification {
+			return nil
+		}
+		return r.createErrorResponse(line, codeUpstreamUnavailable, msg)
 	}
 
-	if r.debug {
-		r.logger.Printf("POST %s -> HTTP %d", postURL, resp.StatusCode)
+	// A 404 for a request carrying a session ID means the server has expired the session
+	// Re-establish it transparently and retry once
+	if resp.StatusCode == http.StatusNotFound && sessionID != "" && !initialize {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if err = r.reestablishSession(ctx, sessionID); err != nil {
+			if ctx.Err() != nil {
+				return r.requestAborted(ctx, collector, timeout)
+			}
+			msg := fmt.Sprintf("Session expired and could not be re-established: %s", err.Error())
+			r.logger.Println(msg)
+			r.flushLog()
+			if notification {
+				return nil
+			}
+			return r.createErrorResponse(line, codeInternalError, msg)
+		}
+
+		var output []byte
+		if resp, output = r.repost(ctx, line, retryable, notification, collector, timeout); resp == nil {
+			return output
+		}
 	}
 
-	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		msg := fmt.Sprintf("Failed to read response: %s", err.Error())
-		r.logger.Println(msg)
-		r.flushLog()
-		return r.createErrorResponse(line, -32603, msg)
+	// The server wants a new access token, so obtain one and retry once
+	if r.reauthorize(ctx, resp) {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		var output []byte
+		if resp, output = r.repost(ctx, line, retryable, notification, collector, timeout); resp == nil {
+			return output
+		}
 	}
+	defer resp.Body.Close()
 
 	// Check status code
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		msg := fmt.Sprintf("Server returned HTTP %d", resp.StatusCode)
+		if reject != nil && reject(resp.StatusCode) {
+			return nil
+		}
+		code, msg, data := httpError(resp)
 		r.logger.Println(msg)
-		if r.debug && len(respBody) > 0 {
-			r.logger.Printf("Server error response: %s", string(respBody))
+		if r.debug && data["body"] != nil {
+			r.logger.Printf("Server error response: %s", data["body"])
+		}
+		r.flushLog()
+		if notification {
+			return nil
+		}
+		return r.createErrorResponseData(line, code, msg, data)
+	}
+
+	// Servers acknowledge notifications with 202 Accepted and no body
+	// Nothing is ever written to the client for a notification
+	if notification {
+		if resp.StatusCode != http.StatusAccepted {
+			r.logger.Printf("Expected HTTP 202 for notification, server returned HTTP %d", resp.StatusCode)
+			r.flushLog()
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+
+		// Once the session is initialized the server may deliver messages on the GET stream
+		if initialized {
+			r.markInitialized()
+		}
+		return nil
+	}
+
+	// The server may reply with an SSE stream instead of a single JSON object
+	// In that case each event is forwarded to the client as it arrives
+	if isEventStream(resp) {
+		stream := streamPOST + collector.key()
+		defer r.data.ClearLastEventID(stream)
+
+		if r.relayEventStream(resp.Body, stream, collector) {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return r.requestAborted(ctx, collector, timeout)
+		}
+
+		// The stream ended before all responses arrived, so try to resume it
+		return r.resumeEventStream(ctx, stream, collector, timeout)
+	}
+
+	return r.relayJSONBody(ctx, resp, collector, timeout)
+}
+
+// repost sends a line again after the session or the access token has been renewed
+// Either the new response or the output for the client is returned.
+func (r *Relay) repost(ctx context.Context, line string, retryable bool, notification bool, collector *responseCollector, timeout time.Duration) (*http.Response, []byte) {
+	resp, _, err := r.postWithRetry(ctx, line, retryable)
+	if ctx.Err() != nil {
+		if resp != nil {
+			_ = resp.Body.Close()
 		}
+		return nil, r.requestAborted(ctx, collector, timeout)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to POST: %s", err.Error())
+		r.logger.Println(msg)
 		r.flushLog()
-		return r.createErrorResponse(line, -32603, msg)
+		if notification {
+			return nil, nil
+		}
+		return nil, r.createErrorResponse(line, codeUpstreamUnavailable, msg)
 	}
+	return resp, nil
+}
 
-	return respBody
+// isEventStream returns true if the response body is a text/event-stream
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
 }
 
-func (r *Relay) runSSE() {
+// relayEventStream forwards each JSON-RPC message in an SSE body to the client
+// The ID of each event is recorded for the stream so that it can be resumed with Last-Event-ID.
+// If a collector is given, relayEventStream returns true as soon as every response has been
+// forwarded.
+func (r *Relay) relayEventStream(body io.Reader, stream string, collector *responseCollector) bool {
+	events := newSSEReader(body, r.maxMessageSize)
+	for {
+		ev, err := events.Next()
+		if err == errMessageTooLarge {
+			// The event is most likely the response, which can't be delivered
+			if ev.id != "" {
+				r.data.SetLastEventID(stream, ev.id)
+			}
+			if collector == nil {
+				r.logger.Printf("Dropped SSE event exceeding the maximum message size of %d bytes", r.maxMessageSize)
+				r.flushLog()
+				continue
+			}
+			if errResp := r.responseTooLarge(collector, fmt.Sprintf("Response exceeds the maximum message size of %d bytes", r.maxMessageSize)); errResp != nil {
+				r.sendToClient(errResp)
+			}
+			return true
+		}
+		if err != nil {
+			if err != io.EOF {
+				r.logger.Printf("SSE response stream error: %s", err.Error())
+				r.flushLog()
+			}
+			return false
+		}
+
+		if ev.id != "" {
+			r.data.SetLastEventID(stream, ev.id)
+		}
+
+		// Streamable HTTP only uses the default "message" event type
+		if ev.event != "" && ev.event != "message" {
+			if r.debug {
+				r.logger.Printf("Ignoring SSE event of type %s", ev.event)
+			}
+			continue
+		}
+
+		if strings.TrimSpace(ev.data) == "" {
+			continue
+		}
+		r.deliver([]byte(ev.data), collector)
+
+		if collector != nil && collector.done() {
+			return true
+		}
+	}
+}
+
+// runSSE relays stdin using the legacy HTTP+SSE transport
+// Lines received before the SSE connection is up are queued, after any pending lines already read by the caller
+func (r *Relay) runSSE(ctx context.Context, stdinChan <-chan string, stdinErrChan <-chan error, pending []string) {
 	// Create a cancellable context for clean shutdown
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel() // Ensure context is cancelled when Run() exits
 
 	// SSE connection needs to be established first and many SSE servers will provide a dynamic endpoint
@@ -281,23 +790,7 @@ func (r *Relay) runSSE() {
 		r.sseClient(ctx, sseConnected)
 	}()
 
-	// Channel for stdin input
-	stdinChan := make(chan string)
-	stdinErrChan := make(chan error)
-	go func() {
-		reader := bufio.NewReader(os.Stdin)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				stdinErrChan <- err
-				return
-			}
-			stdinChan <- line
-		}
-	}()
-
 	// Wait for SSE connection to be established, but also check for stdin closure
-	var pendingLine string
 	var sseReady bool
 
 	for !sseReady {
@@ -305,6 +798,10 @@ func (r *Relay) runSSE() {
 		case <-sseConnected:
 			// SSE connected successfully
 			sseReady = true
+		case <-ctx.Done():
+			r.logger.Println("Received termination signal before SSE connected, shutting down")
+			r.flushLog()
+			return
 		case err := <-stdinErrChan:
 			// stdin closed before SSE connected
 			if err == io.EOF {
@@ -316,10 +813,8 @@ func (r *Relay) runSSE() {
 			return
 		case line := <-stdinChan:
 			// Got stdin input before SSE connected, save it for later
-			if pendingLine == "" {
-				pendingLine = line
-				r.logger.Println("Received stdin input before SSE connected, waiting for SSE...")
-			}
+			pending = append(pending, line)
+			r.logger.Println("Received stdin input before SSE connected, waiting for SSE...")
 			// Continue waiting for SSE or more stdin input
 		}
 	}
@@ -327,9 +822,9 @@ func (r *Relay) runSSE() {
 	r.logger.Println("Starting receive loop on stdin")
 	r.flushLog()
 
-	// Process any pending line
-	if pendingLine != "" {
-		r.processStdinLine(pendingLine)
+	// Process any pending lines in the order they were received
+	for _, line := range pending {
+		r.processStdinLine(line)
 	}
 
 	// Main loop: read and forward requests from stdin
@@ -337,6 +832,10 @@ func (r *Relay) runSSE() {
 		select {
 		case line := <-stdinChan:
 			r.processStdinLine(line)
+		case <-ctx.Done():
+			r.logger.Println("Received termination signal, shutting down")
+			r.flushLog()
+			return
 		case err := <-stdinErrChan:
 			if err == io.EOF {
 				r.logger.Println("EOF on stdin, client has closed the connection")
@@ -353,11 +852,19 @@ func (r *Relay) processStdinLine(line string) {
 	// Trim whitespace and newlines
 	line = strings.TrimSpace(line)
 
-	// Check for MCP JSON-RPC message
-	if strings.HasPrefix(line, "{") {
+	// Check for MCP JSON-RPC message or batch
+	if strings.HasPrefix(line, "{") || strings.HasPrefix(line, "[") {
 		// Attempt to parse as JSON-RPC request
-		var jsonMsg map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &jsonMsg); err == nil {
+		if msgs, _, err := parseLine(line); err == nil {
+			// Replies to server-initiated requests take a separate path
+			if classifyAll(msgs) == kindResponse {
+				r.forwardClientResponse(line)
+				return
+			}
+
+			// Responses arrive on the SSE stream, so cancellation only suppresses them
+			r.handleCancellations(msgs)
+
 			if r.debug {
 				r.logger.Println("C->S:", line)
 			}
@@ -367,17 +874,24 @@ func (r *Relay) processStdinLine(line string) {
 
 			//r.logger.Printf("POSTing JSON-RPC message to server: %s", postURL)
 
-			req, _ := http.NewRequest("POST", postURL, bytes.NewReader([]byte(line)))
-			req.Header.Set("Content-Type", "application/json")
+			// The server only acknowledges the message, so it is not waited for indefinitely
+			// Per-method and per-tool timeouts don't apply as responses arrive on the SSE stream.
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
 
-			// Add custom headers
-			for key, value := range r.headers {
-				req.Header.Set(key, value)
-			}
+			resp, err := r.postLegacy(ctx, postURL, line)
 
-			resp, err := http.DefaultClient.Do(req)
+			// Obtain a new access token and retry once if the server rejected the token
+			if err == nil && r.reauthorize(ctx, resp) {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+				resp, err = r.postLegacy(ctx, postURL, line)
+			}
 			if err != nil {
 				msg := fmt.Sprintf("Failed to forward JSON-RPC message: %s", err.Error())
+				if ctx.Err() != nil {
+					msg = fmt.Sprintf("Failed to forward JSON-RPC message: server did not accept it within %s", notifyTimeout)
+				}
 				r.logger.Println(msg)
 				r.flushLog()
 				r.sendClientError(msg)
@@ -389,16 +903,20 @@ func (r *Relay) processStdinLine(line string) {
 				r.logger.Printf("POST %s -> HTTP %d", postURL, resp.StatusCode)
 			}
 
-			// Close the response body to avoid resource leaks
-			_ = resp.Body.Close()
-
 			// Check for non-2xx status codes
+			// The response will never arrive on the SSE stream, so requests get an error
 			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-				msg := fmt.Sprintf("Server returned HTTP %d for POST request", resp.StatusCode)
+				code, msg, data := httpError(resp)
 				r.logger.Println(msg)
 				r.flushLog()
+				if classifyAll(msgs) == kindRequest {
+					r.sendToClient(r.createErrorResponseData(line, code, msg, data))
+				}
 			}
 
+			// Close the response body to avoid resource leaks
+			_ = resp.Body.Close()
+
 			/* TODO - in non-SEE mode, the body would have to be parsed, JSON extracted, and forwarded to the client
 			   But in SSE mode, the results in the client receiving two responses and getting confused
 
@@ -424,8 +942,22 @@ func (r *Relay) processStdinLine(line string) {
 	r.logger.Printf("Unexpected input: %s", line)
 }
 
+// postLegacy POSTs a message to the endpoint announced on the SSE stream
+func (r *Relay) postLegacy(ctx context.Context, postURL string, line string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", postURL, bytes.NewReader([]byte(line)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Add custom headers
+	r.addHeaders(req)
+
+	return r.httpClient.Do(req)
+}
+
 func (r *Relay) sendClientError(msg string) {
-	r.sendToClient(r.createErrorResponse("", -32603, fmt.Sprintf("Internal error: %s", msg)))
+	r.sendToClient(r.createErrorResponse("", codeInternalError, fmt.Sprintf("Internal error: %s", msg)))
 }
 
 func (r *Relay) sendToClient(msg []byte) {
@@ -458,6 +990,7 @@ func (r *Relay) sendToClient(msg []byte) {
 func (r *Relay) sseClient(ctx context.Context, connected chan bool) {
 	var err error
 	var epTrack int
+	var reauthorized bool // the last attempt was retried straight away with a new access token
 
 	// Get the SSE URL
 	sseURL := r.data.GetSSEURL()
@@ -483,14 +1016,19 @@ func (r *Relay) sseClient(ctx context.Context, connected chan bool) {
 		// Connect to SSE
 		req, _ := http.NewRequest("GET", sseURL, nil)
 		req = req.WithContext(ctx) // Allow request to be cancelled
+		req.Header.Set("Accept", "text/event-stream")
 
 		// Add custom headers
-		for key, value := range r.headers {
-			req.Header.Set(key, value)
+		r.addHeaders(req)
+
+		// Resume after the last event received so that messages sent during the gap are not lost
+		if lastID := r.data.GetLastEventID(streamLegacy); lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+			r.logger.Printf("Resuming SSE stream after event %s", lastID)
 		}
 
 		var resp *http.Response
-		resp, err = http.DefaultClient.Do(req)
+		resp, err = r.httpClient.Do(req)
 		if err != nil {
 			// Check if error is due to context cancellation
 			if ctx.Err() != nil {
@@ -521,7 +1059,15 @@ func (r *Relay) sseClient(ctx context.Context, connected chan bool) {
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 			r.logger.Printf("Warning: SSE server returned HTTP %d", resp.StatusCode)
 			r.flushLog()
+
+			// Reconnect straight away once a new access token has been obtained, but only once
+			// in a row so that a server that keeps rejecting new tokens is not retried in a loop
+			retry := r.reauthorize(ctx, resp) && !reauthorized
+			reauthorized = retry
 			_ = resp.Body.Close()
+			if retry {
+				continue
+			}
 
 			// Wait before retrying, but check for cancellation
 			select {
@@ -535,49 +1081,48 @@ func (r *Relay) sseClient(ctx context.Context, connected chan bool) {
 			continue
 		}
 
+		reauthorized = false
+
 		// Signal that the SSE connection is established
-		connected <- true
+		// Only the first connection is waited for, so don't block on reconnection
+		select {
+		case connected <- true:
+		default:
+		}
 
 		// Read SSE stream
-		reader := bufio.NewReader(resp.Body)
-		var line string
+		events := newSSEReader(resp.Body, r.maxMessageSize)
 		for {
-			line, err = reader.ReadString('\n')
+			var ev *sseEvent
+			ev, err = events.Next()
+			if err == errMessageTooLarge {
+				// The request it answers can't be identified, so the event is dropped
+				r.logger.Printf("Dropped SSE event exceeding the maximum message size of %d bytes", r.maxMessageSize)
+				r.flushLog()
+				continue
+			}
 			if err != nil {
 				r.logger.Printf("SSE stream error: %v", err)
 				r.flushLog()
 				break
 			}
 
-			// Trim whitespace and newlines
-			line = strings.TrimSpace(line)
-
-			// Skip empty lines
-			if line == "" {
-				continue
+			// Track the last event ID for resumption
+			if ev.id != "" {
+				r.data.SetLastEventID(streamLegacy, ev.id)
 			}
 
 			// Detect dynamic endpoint event
-			if strings.HasPrefix(line, "event: endpoint") {
-				epTrack = 1 // pending - next line should a dynamic endpoint
+			if ev.event == "endpoint" {
+				epTrack = 1 // pending - this event's data should be a dynamic endpoint
 				if r.debug {
 					r.logger.Printf("SSE endpoint event received")
 				}
-				continue
-			}
-
-			// Ignore non-data lines
-			if !strings.HasPrefix(line, "data:") {
-				continue
 			}
 
 			// Extract data part
-			tmp := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			tmp := strings.TrimSpace(ev.data)
 			if tmp != "" {
-				if r.debug {
-					//r.logger.Printf("SSE data: %s", tmp)
-				}
-
 				// Is dynamic endpoint pending?
 				if epTrack == 1 {
 					if strings.HasPrefix(tmp, "/") {
@@ -596,8 +1141,9 @@ func (r *Relay) sseClient(ctx context.Context, connected chan bool) {
 					}
 				}
 
-				// Forward data to the client
-				r.sendToClient([]byte(tmp))
+				// Forward data to the client as on the GET stream in HTTP mode: arrays are split
+				// and responses to cancelled requests are dropped
+				r.deliver([]byte(ev.data), nil)
 			}
 		}
 
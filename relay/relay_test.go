@@ -0,0 +1,70 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newTestRelay creates a relay for the MCP server at cfg.Endpoint, in HTTP mode unless
+// another transport is configured
+func newTestRelay(t *testing.T, cfg Config) *Relay {
+	t.Helper()
+	if cfg.Transport == "" {
+		cfg.Transport = "http"
+	}
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	return r
+}
+
+// captureStdout returns what fn writes to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %s", err)
+	}
+
+	output := make(chan string)
+	go func() {
+		content, _ := io.ReadAll(reader)
+		output <- string(content)
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	fn()
+	_ = writer.Close()
+	return <-output
+}
+
+// errorCode returns the error code of a JSON-RPC response, 0 if it isn't an error
+func errorCode(t *testing.T, response string) int {
+	t.Helper()
+	var msg struct {
+		Error *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(response)), &msg); err != nil {
+		t.Fatalf("Invalid response %q: %s", response, err)
+	}
+	if msg.Error == nil {
+		return 0
+	}
+	return msg.Error.Code
+}
@@ -0,0 +1,197 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"maps"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// reloadPollInterval is how often watched files are checked for changes
+const reloadPollInterval = 2 * time.Second
+
+// watchReload reloads the configuration on SIGHUP or when a watched file changes
+// The headers file and the private key of the client credentials grant are watched along
+// with Config.WatchFiles.
+func (r *Relay) watchReload(ctx context.Context) {
+	r.settingsMutex.RLock()
+	enabled := r.config.Reload != nil
+	r.settingsMutex.RUnlock()
+	if !enabled {
+		return
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(reloadPollInterval)
+	defer ticker.Stop()
+
+	modified := r.modTimes()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.reload("SIGHUP")
+			modified = r.modTimes()
+		case <-ticker.C:
+			current := r.modTimes()
+			for _, file := range slices.Sorted(maps.Keys(current)) {
+				if previous, ok := modified[file]; ok && !previous.Equal(current[file]) {
+					r.reload("change of " + file)
+					current = r.modTimes()
+					break
+				}
+			}
+			modified = current
+		}
+	}
+}
+
+// modTimes returns the modification times of the watched files, zero for missing ones
+func (r *Relay) modTimes() map[string]time.Time {
+	r.settingsMutex.RLock()
+	files := append([]string{r.config.HeadersFile}, r.config.WatchFiles...)
+	if r.config.ClientCredentials != nil {
+		files = append(files, r.config.ClientCredentials.PrivateKeyFile)
+	}
+	r.settingsMutex.RUnlock()
+
+	times := make(map[string]time.Time)
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		var modTime time.Time
+		if info, err := os.Stat(file); err == nil {
+			modTime = info.ModTime()
+		}
+		times[file] = modTime
+	}
+	return times
+}
+
+// reload rebuilds the configuration and applies the headers and credentials
+// In-flight requests continue with the settings they were sent with, and the MCP session
+// is kept. Other settings only take effect after a restart.
+func (r *Relay) reload(reason string) {
+	r.logger.Printf("Reloading configuration after %s", reason)
+	r.flushLog()
+
+	r.settingsMutex.RLock()
+	rebuild := r.config.Reload
+	r.settingsMutex.RUnlock()
+
+	cfg, err := rebuild()
+	if err == nil {
+		err = r.apply(cfg)
+	}
+	if err != nil {
+		r.logger.Printf("Reload failed, keeping the current configuration: %s", err.Error())
+	}
+	r.flushLog()
+}
+
+// apply replaces the headers and token source with those of cfg and logs what changed
+func (r *Relay) apply(cfg Config) error {
+	r.settingsMutex.RLock()
+	current := r.config
+	oldHeaders := r.headers
+	r.settingsMutex.RUnlock()
+
+	headers, err := r.loadHeaders(cfg.HeadersFile, cfg.Headers)
+	if err != nil {
+		return err
+	}
+
+	// Header values are not logged, as most headers that change are credentials
+	var changes []string
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		old, ok := oldHeaders[name]
+		switch {
+		case !ok:
+			changes = append(changes, "header "+name+" added")
+		case old != headers[name]:
+			changes = append(changes, "header "+name+" changed")
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(oldHeaders)) {
+		if _, ok := headers[name]; !ok {
+			changes = append(changes, "header "+name+" removed")
+		}
+	}
+
+	// The credentials are always reloaded, as a key file or token command may give a
+	// different result even if the configuration is the same. An OAuth token source is
+	// kept unless its configuration changed, so that the user is not asked to authorize again.
+	tokens := r.tokenSource()
+	credentialsChanged := cfg.TokenCommand != current.TokenCommand ||
+		!reflect.DeepEqual(cfg.ClientCredentials, current.ClientCredentials) ||
+		!reflect.DeepEqual(cfg.OAuth, current.OAuth)
+	if credentialsChanged || cfg.OAuth == nil {
+		if tokens, err = r.newTokenSource(cfg); err != nil {
+			return err
+		}
+	}
+	if credentialsChanged {
+		changes = append(changes, "credentials changed")
+	}
+
+	updated := current
+	updated.Headers = cfg.Headers
+	updated.HeadersFile = cfg.HeadersFile
+	updated.OAuth = cfg.OAuth
+	updated.ClientCredentials = cfg.ClientCredentials
+	updated.TokenCommand = cfg.TokenCommand
+
+	r.settingsMutex.Lock()
+	r.headers = headers
+	r.tokens = tokens
+	r.config = updated
+	r.settingsMutex.Unlock()
+
+	if len(changes) == 0 {
+		r.logger.Println("Reloaded configuration: headers and credentials unchanged")
+	} else {
+		r.logger.Printf("Reloaded configuration: %s", strings.Join(changes, ", "))
+	}
+	if restart := restartRequired(current, cfg); len(restart) > 0 {
+		r.logger.Printf("Changes to %s take effect after a restart", strings.Join(restart, ", "))
+	}
+	return nil
+}
+
+// restartRequired returns the options that differ between two configurations but can't
+// be changed while running
+func restartRequired(before Config, after Config) []string {
+	var options []string
+	check := func(option string, a interface{}, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			options = append(options, option)
+		}
+	}
+	check("url", before.Endpoint, after.Endpoint)
+	check("transport", before.Transport, after.Transport)
+	check("unix-socket", before.UnixSocket, after.UnixSocket)
+	check("debug", before.Debug, after.Debug)
+	check("concurrency", before.Concurrency, after.Concurrency)
+	check("proxy", before.Proxy, after.Proxy)
+	check("no-proxy", before.NoProxy, after.NoProxy)
+	check("TLS options", before.TLS, after.TLS)
+	check("timeouts", []interface{}{before.Timeout, before.MethodTimeouts, before.ToolTimeouts}, []interface{}{after.Timeout, after.MethodTimeouts, after.ToolTimeouts})
+	check("retries", before.Retry, after.Retry)
+	check("max-message-size", before.MaxMessageSize, after.MaxMessageSize)
+	return options
+}
@@ -0,0 +1,236 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryMethods are the methods that are safe to retry by default
+var DefaultRetryMethods = []string{"initialize", "*/list", "resources/read", "ping"}
+
+// RetryPolicy controls automatic retries of transient upstream failures in HTTP mode
+// Connection timeouts, refused or reset connections, temporary DNS failures and HTTP 429,
+// 502, 503 and 504 are considered transient.
+type RetryPolicy struct {
+	MaxRetries int           // retries after the first attempt, zero disables retries
+	BaseDelay  time.Duration // delay before the first retry, doubled for each further retry
+	MaxDelay   time.Duration // upper bound for the delay between attempts
+	Methods    []string      // methods that may be retried: exact, "*" for all, "*/list" or "tools/*" patterns
+	ToolHints  bool          // also retry tools/call for tools annotated readOnlyHint or idempotentHint
+}
+
+// DefaultRetryPolicy returns the retry policy used unless configured otherwise
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+		Methods:    DefaultRetryMethods,
+		ToolHints:  true,
+	}
+}
+
+// retryableTools records which tools are annotated as safe to retry
+// It is populated from tools/list results.
+type retryableTools struct {
+	mutex sync.RWMutex
+	names map[string]bool
+}
+
+// record updates the registry from a tools/list result
+func (t *retryableTools) record(result map[string]interface{}) {
+	tools, _ := result["tools"].([]interface{})
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.names == nil {
+		t.names = make(map[string]bool)
+	}
+	for _, tool := range tools {
+		tool, _ := tool.(map[string]interface{})
+		name, _ := tool["name"].(string)
+		if name == "" {
+			continue
+		}
+		annotations, _ := tool["annotations"].(map[string]interface{})
+		readOnly, _ := annotations["readOnlyHint"].(bool)
+		idempotent, _ := annotations["idempotentHint"].(bool)
+		t.names[name] = readOnly || idempotent
+	}
+}
+
+func (t *retryableTools) isRetryable(name string) bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.names[name]
+}
+
+// matchMethod returns true if method matches a retry pattern
+func matchMethod(pattern string, method string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(method, pattern[1:])
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(method, pattern[:len(pattern)-1])
+	default:
+		return pattern == method
+	}
+}
+
+// isRetryable returns true if the policy allows the request to be retried
+func (r *Relay) isRetryable(jsonMsg map[string]interface{}) bool {
+	method := methodOf(jsonMsg)
+	for _, pattern := range r.retry.Methods {
+		if matchMethod(pattern, method) {
+			return true
+		}
+	}
+	return method == "tools/call" && r.retry.ToolHints && r.tools.isRetryable(toolName(jsonMsg))
+}
+
+// isBatchRetryable returns true if every request among msgs may be retried
+// Notifications and batches without requests are not retried.
+func (r *Relay) isBatchRetryable(msgs []map[string]interface{}) bool {
+	if r.retry.MaxRetries < 1 {
+		return false
+	}
+	requests := 0
+	for _, jsonMsg := range msgs {
+		if classify(jsonMsg) != kindRequest {
+			continue
+		}
+		if !r.isRetryable(jsonMsg) {
+			return false
+		}
+		requests++
+	}
+	return requests > 0
+}
+
+// isTransientStatus returns true for HTTP statuses that are worth retrying
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTransientError returns true for connection failures that are worth retrying
+// Timeouts, refused or reset connections and temporary DNS failures qualify. Others, such
+// as certificate errors or a pin mismatch, would only fail again.
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+}
+
+// retryAfter returns the delay requested by a Retry-After header, if any
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		delay := time.Until(when)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// backoff returns the jittered delay before the given retry (starting at 1)
+// The delay grows exponentially and is randomized between half and all of its value.
+func (r *Relay) backoff(retry int) time.Duration {
+	delay := r.retry.BaseDelay
+	for i := 1; i < retry && delay < r.retry.MaxDelay; i++ {
+		delay *= 2
+	}
+	if r.retry.MaxDelay > 0 && delay > r.retry.MaxDelay {
+		delay = r.retry.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// postWithRetry POSTs a message, retrying transient failures if retryable is true
+// The last response or error is returned as is for the caller to handle. That is also the
+// case if the server asks for a longer wait (Retry-After) than MaxDelay allows, or the
+// wait would outlast the request's timeout, so that the client learns why it failed.
+func (r *Relay) postWithRetry(ctx context.Context, line string, retryable bool) (*http.Response, string, error) {
+	for retry := 1; ; retry++ {
+		resp, sessionID, err := r.postMessage(ctx, line)
+		if !retryable || retry > r.retry.MaxRetries || ctx.Err() != nil {
+			return resp, sessionID, err
+		}
+
+		// Decide whether the failure is transient and how long to wait
+		var reason string
+		delay := r.backoff(retry)
+		switch {
+		case err != nil && isTransientError(err):
+			reason = err.Error()
+		case err == nil && isTransientStatus(resp.StatusCode):
+			reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+			if after, ok := retryAfter(resp); ok {
+				if r.retry.MaxDelay > 0 && after > r.retry.MaxDelay {
+					r.logger.Printf("POST failed (%s), not retrying as the server asks to wait %s", reason, after)
+					r.flushLog()
+					return resp, sessionID, err
+				}
+				delay = after
+			}
+		default:
+			return resp, sessionID, err
+		}
+		if left, ok := timeLeft(ctx); ok && delay >= left {
+			r.logger.Printf("POST failed (%s), not retrying as the request would time out first", reason)
+			r.flushLog()
+			return resp, sessionID, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		r.logger.Printf("POST failed (%s), retrying in %s (retry %d of %d)", reason, delay.Round(time.Millisecond), retry, r.retry.MaxRetries)
+		r.flushLog()
+
+		select {
+		case <-ctx.Done():
+			return nil, sessionID, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
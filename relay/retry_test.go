@@ -0,0 +1,81 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&url.Error{Op: "Post", URL: "http://x", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, true},
+		{&url.Error{Op: "Post", URL: "http://x", Err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, true},
+		{&url.Error{Op: "Post", URL: "http://x", Err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}}, true},
+		{&url.Error{Op: "Post", URL: "http://x", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}, false},
+		{&url.Error{Op: "Post", URL: "https://x", Err: x509.UnknownAuthorityError{}}, false},
+		{&url.Error{Op: "Post", URL: "https://x", Err: errors.New("no certificate matches the configured pins")}, false},
+	}
+	for _, test := range tests {
+		if got := isTransientError(test.err); got != test.want {
+			t.Errorf("isTransientError(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestRetryAfterBeyondMaxDelay(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	r := newTestRelay(t, Config{Endpoint: server.URL, Retry: DefaultRetryPolicy(), Timeout: time.Minute})
+	start := time.Now()
+	response := r.processHTTPRequest(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Request took %s", elapsed)
+	}
+	if code := errorCode(t, string(response)); code != codeRateLimited {
+		t.Errorf("Expected error %d, got %d", codeRateLimited, code)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected 1 request, got %d", n)
+	}
+}
+
+func TestRetryBeyondTimeout(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	r := newTestRelay(t, Config{Endpoint: server.URL, Retry: DefaultRetryPolicy(), Timeout: time.Second})
+	response := r.processHTTPRequest(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if code := errorCode(t, string(response)); code != codeUpstreamUnavailable {
+		t.Errorf("Expected error %d, got %d", codeUpstreamUnavailable, code)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected 1 request, got %d", n)
+	}
+}
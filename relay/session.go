@@ -0,0 +1,182 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sessionTerminateTimeout bounds how long shutdown waits for the server to acknowledge DELETE
+const sessionTerminateTimeout = 5 * time.Second
+
+// sessionReplayTimeout bounds how long re-establishing an expired session may take
+// Other requests wait for it, and for a notification so does stdin.
+const sessionReplayTimeout = 30 * time.Second
+
+// terminateSession explicitly ends the MCP session with HTTP DELETE
+// This releases any server-side state held for the session
+func (r *Relay) terminateSession() {
+	sessionID := r.data.GetSessionID()
+	if sessionID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sessionTerminateTimeout)
+	defer cancel()
+
+	deleteURL := r.data.GetPostURL()
+	req, err := r.newHTTPRequest(ctx, "DELETE", deleteURL, nil)
+	if err != nil {
+		r.logger.Printf("Failed to create DELETE request: %s", err.Error())
+		return
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		r.logger.Printf("Failed to terminate session %s: %s", sessionID, err.Error())
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if r.debug {
+		r.logger.Printf("DELETE %s -> HTTP %d", deleteURL, resp.StatusCode)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		// Servers are allowed to refuse client-initiated termination
+		r.logger.Println("Server does not support explicit session termination")
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		r.logger.Printf("Terminated session %s", sessionID)
+	default:
+		r.logger.Printf("Session termination: server returned HTTP %d", resp.StatusCode)
+	}
+
+	r.data.SetSessionID("")
+}
+
+// handshake holds the client's original initialize request and notifications/initialized
+// They are replayed when the server expires the session
+type handshake struct {
+	mutex       sync.Mutex
+	initialize  string
+	initialized string
+}
+
+func (h *handshake) setInitialize(line string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.initialize = line
+}
+
+func (h *handshake) setInitialized(line string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.initialized = line
+}
+
+func (h *handshake) get() (string, string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.initialize, h.initialized
+}
+
+// reestablishSession replays the cached handshake after the server returned 404 for the expired session
+// The replayed exchange is not forwarded to the client. If another request has already
+// re-established the session, nothing is done. The replay ends if ctx, the context of the
+// request that found the session expired, is done.
+func (r *Relay) reestablishSession(ctx context.Context, expired string) error {
+	r.reinitMutex.Lock()
+	defer r.reinitMutex.Unlock()
+
+	if current := r.data.GetSessionID(); current != "" && current != expired {
+		return nil
+	}
+
+	initialize, initialized := r.handshake.get()
+	if initialize == "" {
+		return errors.New("no initialize request to replay")
+	}
+
+	r.logger.Printf("Session %s not found on server, re-establishing session", expired)
+	r.flushLog()
+
+	// Clear the session so that initialize is sent without it and the new ID is captured
+	// Event IDs belong to the old session and can't be used to resume streams of the new one
+	r.data.SetSessionID("")
+	r.data.ClearLastEventID(streamGET)
+
+	ctx, cancel := context.WithTimeout(ctx, sessionReplayTimeout)
+	defer cancel()
+
+	resp, _, err := r.postMessage(ctx, initialize)
+	if err != nil {
+		return fmt.Errorf("initialize failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return fmt.Errorf("initialize returned HTTP %d", resp.StatusCode)
+	}
+
+	// The result isn't forwarded to the client, but the negotiated version is recorded
+	r.readReplayedResult(resp)
+
+	if initialized != "" {
+		resp, _, err = r.postMessage(ctx, initialized)
+		if err != nil {
+			return fmt.Errorf("notifications/initialized failed: %w", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("notifications/initialized returned HTTP %d", resp.StatusCode)
+		}
+	}
+
+	r.logger.Printf("Re-established session %s", r.data.GetSessionID())
+	r.flushLog()
+	return nil
+}
+
+// readReplayedResult reads the server's reply to a replayed initialize request
+// The reply may be JSON or an SSE stream. Only the negotiated protocol version is used.
+func (r *Relay) readReplayedResult(resp *http.Response) {
+	defer resp.Body.Close()
+
+	observe := func(data []byte) bool {
+		for _, msg := range splitMessages(data) {
+			var jsonMsg map[string]interface{}
+			if json.Unmarshal(msg, &jsonMsg) == nil && classify(jsonMsg) == kindResponse {
+				r.observeResponse("initialize", jsonMsg)
+				return true
+			}
+		}
+		return false
+	}
+
+	if !isEventStream(resp) {
+		body, _ := io.ReadAll(resp.Body)
+		observe(body)
+		return
+	}
+
+	events := newSSEReader(resp.Body, r.maxMessageSize)
+	for {
+		ev, err := events.Next()
+		if err != nil || observe([]byte(ev.data)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReestablishSessionTimeout checks that a server that hangs while the session is being
+// re-established doesn't hold up the request beyond its timeout
+func TestReestablishSessionTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if req.Header.Get("Mcp-Session-Id") == "expired" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.Contains(string(body), `"initialize"`) {
+			<-req.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	r := newTestRelay(t, Config{Endpoint: server.URL, Timeout: 200 * time.Millisecond})
+	r.handshake.setInitialize(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}`)
+	r.data.SetSessionID("expired")
+
+	start := time.Now()
+	response := r.processHTTPRequest(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Request took %s", elapsed)
+	}
+	if code := errorCode(t, string(response)); code != codeRequestTimeout {
+		t.Errorf("Expected error %d, got %d", codeRequestTimeout, code)
+	}
+}
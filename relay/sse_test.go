@@ -0,0 +1,72 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestSSEModeDelivery checks that arrays on the SSE stream are split and that responses
+// to cancelled requests are dropped
+func TestSSEModeDelivery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: endpoint\ndata: /messages\n\n")
+		fmt.Fprint(w, "data: [{\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{}},\n")
+		fmt.Fprint(w, "data:  {\"jsonrpc\":\"2.0\",\"id\":2,\"result\":{}}]\n\n")
+		fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"id\":3,\"result\":{}}\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	r := newTestRelay(t, Config{Endpoint: server.URL + "/sse", Transport: "sse"})
+	r.inflight.cancel(idKey(2))
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %s", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.sseClient(ctx, make(chan bool, 1))
+		close(done)
+	}()
+
+	// The last event marks the end of the test
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if strings.Contains(scanner.Text(), `"id":3`) {
+			break
+		}
+	}
+	cancel()
+	<-done
+
+	want := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{}}`,
+		`{"jsonrpc":"2.0","id":3,"result":{}}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Client received:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,171 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultMaxMessageSize is the default limit for a single message from the server
+const DefaultMaxMessageSize = 64 << 20
+
+// streamThreshold is the size above which a JSON response is streamed to the client
+// Smaller responses are read completely, which allows them to be inspected and re-batched.
+// Responses without a Content-Length are always read completely, as their size can only
+// be checked against the maximum message size once they have been received.
+const streamThreshold = 64 << 10
+
+var errMessageTooLarge = errors.New("message exceeds the maximum message size")
+
+// relayJSONBody forwards a JSON (non-SSE) response body to the client
+// Large responses to a single request are copied straight to stdout rather than being
+// held in memory. Responses larger than the maximum message size are replaced by an error.
+func (r *Relay) relayJSONBody(ctx context.Context, resp *http.Response, collector *responseCollector, timeout time.Duration) []byte {
+	if r.maxMessageSize > 0 && resp.ContentLength > r.maxMessageSize {
+		return r.responseTooLarge(collector, fmt.Sprintf("Response of %d bytes exceeds the maximum message size of %d bytes", resp.ContentLength, r.maxMessageSize))
+	}
+
+	// Only a response whose size is known to be within the limit is streamed
+	body := bufio.NewReaderSize(resp.Body, streamThreshold)
+	if resp.ContentLength >= streamThreshold && r.canStream(collector) {
+		head, err := body.Peek(streamThreshold)
+		if err == nil && bytes.HasPrefix(bytes.TrimSpace(head), []byte("{")) {
+			return r.streamJSONBody(ctx, body, collector, timeout)
+		}
+	}
+
+	// Read response body
+	var reader io.Reader = body
+	if r.maxMessageSize > 0 {
+		reader = io.LimitReader(body, r.maxMessageSize+1)
+	}
+	respBody, err := io.ReadAll(reader)
+	if ctx.Err() != nil {
+		return r.requestAborted(ctx, collector, timeout)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to read response: %s", err.Error())
+		r.logger.Println(msg)
+		r.flushLog()
+		return collector.fail(codeInternalError, msg)
+	}
+	if r.maxMessageSize > 0 && int64(len(respBody)) > r.maxMessageSize {
+		return r.responseTooLarge(collector, fmt.Sprintf("Response exceeds the maximum message size of %d bytes", r.maxMessageSize))
+	}
+
+	// Forward the response, splitting or re-batching it to match what the client sent
+	r.deliver(respBody, collector)
+	if !collector.done() {
+		msg := "Server reply did not include a response to every request"
+		r.logger.Println(msg)
+		r.flushLog()
+		return collector.fail(codeInternalError, msg)
+	}
+	return nil
+}
+
+// canStream returns true if the response to the collector's requests may be streamed
+// Batches are re-assembled, and some responses are inspected by the relay (see observeResponse),
+// so only the response to a single request for any other method qualifies.
+func (r *Relay) canStream(collector *responseCollector) bool {
+	if collector.batch || len(collector.ids) != 1 {
+		return false
+	}
+
+	key := collector.ids[0]
+	switch collector.methods[key] {
+	case "initialize", "tools/list":
+		return false
+	}
+
+	// Responses to cancelled requests are dropped, which is only possible when buffered
+	return !r.inflight.isCancelled(key)
+}
+
+// streamJSONBody copies a JSON response to stdout as it arrives, holding the writer lock
+// The caller has checked the size of the response against the maximum message size.
+// A failure after the first byte has been written can't be recovered. This happens if the
+// connection fails or the request is cancelled or times out while the body is being copied.
+// The truncated line is terminated so that later messages remain readable, and is followed
+// by an error response unless the client cancelled the request.
+func (r *Relay) streamJSONBody(ctx context.Context, body *bufio.Reader, collector *responseCollector, timeout time.Duration) []byte {
+	r.writerMutex.Lock()
+	written, err := io.Copy(&compactWriter{w: os.Stdout}, body)
+	if _, writeErr := os.Stdout.Write([]byte{'\n'}); writeErr != nil {
+		r.logger.Printf("Failed to write response body to stdout: %s", writeErr.Error())
+	}
+	_ = os.Stdout.Sync()
+	r.writerMutex.Unlock()
+
+	if r.debug {
+		r.logger.Printf("S->C: streamed %d byte response to request %s", written, collector.key())
+	}
+
+	if ctx.Err() != nil {
+		return r.requestAborted(ctx, collector, timeout)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to read response: %s", err.Error())
+		r.logger.Println(msg)
+		r.flushLog()
+		return collector.fail(codeInternalError, msg)
+	}
+	return nil
+}
+
+// responseTooLarge logs msg and returns an error for the requests still awaiting a response
+func (r *Relay) responseTooLarge(collector *responseCollector, msg string) []byte {
+	r.logger.Println(msg)
+	r.flushLog()
+	return collector.fail(codeResponseTooLarge, msg)
+}
+
+// compactWriter removes whitespace outside of strings from the JSON written to it,
+// so that a streamed message stays on a single line
+type compactWriter struct {
+	w        io.Writer
+	buf      []byte
+	inString bool
+	escaped  bool
+}
+
+func (cw *compactWriter) Write(p []byte) (int, error) {
+	out := cw.buf[:0]
+	for _, b := range p {
+		if cw.inString {
+			switch {
+			case cw.escaped:
+				cw.escaped = false
+			case b == '\\':
+				cw.escaped = true
+			case b == '"':
+				cw.inString = false
+			}
+		} else {
+			switch b {
+			case ' ', '\t', '\r', '\n':
+				continue
+			case '"':
+				cw.inString = true
+			}
+		}
+		out = append(out, b)
+	}
+	cw.buf = out
+
+	if _, err := cw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,88 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// largeResult returns a response to request 1 of roughly size bytes, pretty-printed
+func largeResult(size int) string {
+	return fmt.Sprintf("{\n  \"jsonrpc\": \"2.0\",\n  \"id\": 1,\n  \"result\": {\"text\": \"%s\"}\n}", strings.Repeat("x", size))
+}
+
+func TestStreamKnownLength(t *testing.T) {
+	body := largeResult(200000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	r := newTestRelay(t, Config{Endpoint: server.URL, MaxMessageSize: 300000})
+	var response []byte
+	output := captureStdout(t, func() {
+		response = r.processHTTPRequest(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read"}}`)
+	})
+
+	if response != nil {
+		t.Fatalf("Unexpected response %s", response)
+	}
+	if strings.Count(output, "\n") != 1 || !strings.HasSuffix(output, "\n") {
+		t.Fatalf("Streamed response is not a single line")
+	}
+	if errorCode(t, output) != 0 {
+		t.Fatalf("Streamed response is an error: %.100s", output)
+	}
+}
+
+func TestStreamOversizeChunked(t *testing.T) {
+	// Without a Content-Length the body is sent chunked
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(largeResult(200000)))
+	}))
+	defer server.Close()
+
+	r := newTestRelay(t, Config{Endpoint: server.URL, MaxMessageSize: 100000})
+	var response []byte
+	output := captureStdout(t, func() {
+		response = r.processHTTPRequest(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read"}}`)
+	})
+
+	if output != "" {
+		t.Fatalf("Partial response written to stdout: %.100s", output)
+	}
+	if code := errorCode(t, string(response)); code != codeResponseTooLarge {
+		t.Fatalf("Expected error %d, got %d", codeResponseTooLarge, code)
+	}
+}
+
+func TestCompactWriter(t *testing.T) {
+	input := "{\n  \"text\": \"a b\\\"  c\\\\\",\r\n\t\"list\": [ 1, 2 ],\n  \"nested\": { \"s\": \" \\n \" }\n}\n"
+	want := `{"text":"a b\"  c\\","list":[1,2],"nested":{"s":" \n "}}`
+
+	// Strings and escapes split across writes must be tracked
+	for _, size := range []int{1, 2, 3, 7, len(input)} {
+		var buf bytes.Buffer
+		cw := &compactWriter{w: &buf}
+		for i := 0; i < len(input); i += size {
+			chunk := input[i:min(i+size, len(input))]
+			if n, err := cw.Write([]byte(chunk)); err != nil || n != len(chunk) {
+				t.Fatalf("Write returned %d, %v", n, err)
+			}
+		}
+		if buf.String() != want {
+			t.Errorf("Writes of %d bytes produced %s, want %s", size, buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout is the default time allowed for a request in HTTP mode
+const DefaultTimeout = 5 * time.Minute
+
+// cancelNotifyTimeout bounds how long the relay waits for the server to accept notifications/cancelled
+const cancelNotifyTimeout = 5 * time.Second
+
+// notifyTimeout bounds how long the relay waits for the server to accept a notification or
+// a response from the client, which are forwarded in order and hold up reading stdin
+// It also applies to every message in SSE mode, where responses arrive on the SSE stream.
+const notifyTimeout = 30 * time.Second
+
+// Causes for ending a request's context
+var (
+	errClientCancelled = errors.New("cancelled by client")
+	errRequestTimeout  = errors.New("request timed out")
+)
+
+// deadline cancels a request when its timeout expires
+// The timeout restarts whenever the server reports progress for the request.
+type deadline struct {
+	timer   *time.Timer
+	timeout time.Duration
+	expires atomic.Int64 // when the timeout expires, in Unix nanoseconds
+}
+
+func newDeadline(timeout time.Duration, cancel context.CancelCauseFunc) *deadline {
+	d := &deadline{
+		timeout: timeout,
+		timer: time.AfterFunc(timeout, func() {
+			cancel(errRequestTimeout)
+		}),
+	}
+	d.expires.Store(time.Now().Add(timeout).UnixNano())
+	return d
+}
+
+// extend restarts the timeout
+func (d *deadline) extend() {
+	d.expires.Store(time.Now().Add(d.timeout).UnixNano())
+	d.timer.Reset(d.timeout)
+}
+
+// deadlineKey is the context key of a request's deadline
+type deadlineKey struct{}
+
+// timeLeft returns how long a request may still run, false if it has no timeout
+// The deadline may still be extended by progress notifications.
+func timeLeft(ctx context.Context) (time.Duration, bool) {
+	if d, ok := ctx.Value(deadlineKey{}).(*deadline); ok {
+		return time.Until(time.Unix(0, d.expires.Load())), true
+	}
+	if when, ok := ctx.Deadline(); ok {
+		return time.Until(when), true
+	}
+	return 0, false
+}
+
+// stop releases the timer
+func (d *deadline) stop() {
+	d.timer.Stop()
+}
+
+// timeoutFor returns the timeout for a request
+// A per-tool timeout for tools/call takes precedence over a per-method timeout,
+// which takes precedence over the default. Zero means no timeout.
+func (r *Relay) timeoutFor(jsonMsg map[string]interface{}) time.Duration {
+	method := methodOf(jsonMsg)
+	if method == "tools/call" {
+		if timeout, ok := r.toolTimeouts[toolName(jsonMsg)]; ok {
+			return timeout
+		}
+	}
+	if timeout, ok := r.methodTimeouts[method]; ok {
+		return timeout
+	}
+	return r.timeout
+}
+
+// batchTimeout returns the longest timeout of the requests in msgs
+// A batch is sent as a single POST, so it is allowed as long as its slowest member.
+func (r *Relay) batchTimeout(msgs []map[string]interface{}) time.Duration {
+	var longest time.Duration
+	for _, jsonMsg := range msgs {
+		if classify(jsonMsg) != kindRequest {
+			continue
+		}
+		timeout := r.timeoutFor(jsonMsg)
+		if timeout == 0 {
+			return 0
+		}
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+	return longest
+}
+
+// toolName returns the name of the tool called by a tools/call request
+func toolName(jsonMsg map[string]interface{}) string {
+	params, _ := jsonMsg["params"].(map[string]interface{})
+	name, _ := params["name"].(string)
+	return name
+}
+
+// progressToken returns the progress token of a request or progress notification, if any
+func progressToken(jsonMsg map[string]interface{}) (string, bool) {
+	params, _ := jsonMsg["params"].(map[string]interface{})
+	if params == nil {
+		return "", false
+	}
+
+	// Progress notifications carry the token directly
+	if methodOf(jsonMsg) == "notifications/progress" {
+		token, ok := params["progressToken"]
+		if !ok {
+			return "", false
+		}
+		return idKey(token), true
+	}
+
+	// Requests carry it in _meta
+	meta, _ := params["_meta"].(map[string]interface{})
+	token, ok := meta["progressToken"]
+	if !ok {
+		return "", false
+	}
+	return idKey(token), true
+}
+
+// requestAborted returns the reply for a request whose context has ended
+// Nothing is returned if the client cancelled it. If it timed out, the server is told to
+// stop working on it and the client receives an error response with the original id.
+func (r *Relay) requestAborted(ctx context.Context, collector *responseCollector, timeout time.Duration) []byte {
+	if !errors.Is(context.Cause(ctx), errRequestTimeout) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("Request timed out after %s", timeout)
+	r.logger.Printf("Request %s timed out after %s", collector.key(), timeout)
+	r.flushLog()
+
+	for _, id := range collector.pendingIDs() {
+		r.cancelUpstream(id, msg)
+	}
+	return collector.fail(codeRequestTimeout, msg)
+}
+
+// cancelUpstream sends notifications/cancelled for a request to the server
+func (r *Relay) cancelUpstream(id interface{}, reason string) {
+	notification, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "notifications/cancelled",
+		"params": map[string]interface{}{
+			"requestId": id,
+			"reason":    reason,
+		},
+	})
+
+	if r.debug {
+		r.logger.Println("C->S (notification):", string(notification))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelNotifyTimeout)
+	defer cancel()
+
+	resp, _, err := r.postMessage(ctx, string(notification))
+	if err != nil {
+		r.logger.Printf("Failed to send notifications/cancelled: %s", err.Error())
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,162 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// TLSOptions configures TLS for connections to the server
+// The zero value uses the system roots and Go's defaults.
+type TLSOptions struct {
+	CertFile       string   // client certificate in PEM format, may also contain the key
+	KeyFile        string   // client private key in PEM format, defaults to CertFile
+	PKCS12File     string   // client certificate, key and chain in a PKCS#12 (.p12/.pfx) bundle
+	PKCS12Password string   // password of the PKCS#12 bundle
+	CAFile         string   // PEM bundle of CA certificates to trust instead of the system roots
+	ServerName     string   // name to verify the server certificate against instead of the URL's host
+	MinVersion     string   // minimum TLS version: "1.0", "1.1", "1.2" or "1.3"
+	Pins           []string // base64 SHA-256 hashes of trusted SubjectPublicKeyInfo, optionally prefixed with "sha256/"
+}
+
+// tlsVersions maps the accepted MinVersion values to TLS versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var errPinMismatch = errors.New("server certificate does not match any pinned public key")
+
+// newTLSConfig builds the TLS configuration for the HTTP transport
+// nil is returned if no options are set.
+func (r *Relay) newTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if opts.CertFile == "" && opts.PKCS12File == "" && opts.CAFile == "" && opts.ServerName == "" &&
+		opts.MinVersion == "" && len(opts.Pins) == 0 {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: opts.ServerName}
+
+	if opts.MinVersion != "" {
+		version, ok := tlsVersions[opts.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("Invalid minimum TLS version '%s' (must be 1.0, 1.1, 1.2 or 1.3)", opts.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	// Client certificate for mutual TLS
+	switch {
+	case opts.CertFile != "" && opts.PKCS12File != "":
+		return nil, errors.New("A client certificate may be given as PEM or PKCS#12, but not both")
+	case opts.CertFile != "":
+		keyFile := opts.KeyFile
+		if keyFile == "" {
+			keyFile = opts.CertFile
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading client certificate: %s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		r.logger.Printf("Using client certificate %s", opts.CertFile)
+	case opts.PKCS12File != "":
+		cert, err := loadPKCS12(opts.PKCS12File, opts.PKCS12Password)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		r.logger.Printf("Using client certificate %s from %s", cert.Leaf.Subject, opts.PKCS12File)
+	case opts.KeyFile != "":
+		return nil, errors.New("A client key requires a client certificate")
+	}
+
+	// Private CA
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading CA bundle: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CA bundle %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+		r.logger.Printf("Trusting CA certificates from %s", opts.CAFile)
+	}
+
+	// Public key pinning is checked in addition to normal certificate verification
+	if len(opts.Pins) > 0 {
+		pins := make(map[string]bool)
+		for _, pin := range opts.Pins {
+			pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+			if hash, err := base64.StdEncoding.DecodeString(pin); err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("Invalid public key pin '%s' (must be a base64 SHA-256 hash)", pin)
+			}
+			pins[pin] = true
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs, pins)
+		}
+		r.logger.Printf("Pinning %d server public key(s)", len(pins))
+	}
+
+	return tlsConfig, nil
+}
+
+// loadPKCS12 reads a client certificate, its key and any intermediate certificates from a PKCS#12 bundle
+func loadPKCS12(file string, password string) (tls.Certificate, error) {
+	pfx, err := os.ReadFile(file)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("Error reading PKCS#12 bundle: %s", err.Error())
+	}
+
+	key, leaf, chain, err := pkcs12.DecodeChain(pfx, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("Error decoding PKCS#12 bundle %s: %s", file, err.Error())
+	}
+
+	cert := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range chain {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+	}
+	return cert, nil
+}
+
+// verifyPins succeeds if any certificate in a verified chain has a pinned public key
+// Unverified certificates sent by the server are not considered, except for the leaf.
+func verifyPins(cs tls.ConnectionState, pins map[string]bool) error {
+	var certs []*x509.Certificate
+	for _, chain := range cs.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+	if len(certs) == 0 && len(cs.PeerCertificates) > 0 {
+		certs = cs.PeerCertificates[:1]
+	}
+
+	for _, cert := range certs {
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if pins[base64.StdEncoding.EncodeToString(hash[:])] {
+			return nil
+		}
+	}
+	return errPinMismatch
+}
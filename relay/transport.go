@@ -0,0 +1,127 @@
+/******************************************************************************
+ * Copyright (c) 2025 Tenebris Technologies Inc.                              *
+ * See LICENSE for details.                                                   *
+ ******************************************************************************/
+
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// unixScheme is the URL scheme for servers listening on a Unix domain socket
+// The socket path is followed by a colon and the HTTP path, e.g. unix:///run/mcp/server.sock:/mcp
+const unixScheme = "unix://"
+
+// unixHost is the host used in the URL and Host header of requests sent over a Unix socket
+const unixHost = "localhost"
+
+// parseUnixURL splits a unix:// URL into the socket path and an equivalent http:// URL
+func parseUnixURL(endpoint string) (socket string, httpURL string, err error) {
+	rest := strings.TrimPrefix(endpoint, unixScheme)
+	socket, path, found := strings.Cut(rest, ":")
+	if !found || path == "" {
+		path = "/"
+	}
+	if socket == "" {
+		return "", "", fmt.Errorf("Error parsing URL '%s': missing socket path", endpoint)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", "", fmt.Errorf("Error parsing URL '%s': HTTP path must start with /", endpoint)
+	}
+	return socket, fmt.Sprintf("http://%s%s", unixHost, path), nil
+}
+
+// newHTTPClient creates the persistent HTTP client used for all requests to the server
+// If unixSocket is set every connection is made to that socket regardless of the URL.
+func (r *Relay) newHTTPClient(cfg Config, unixSocket string) (*http.Client, error) {
+	transport := &http.Transport{
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	if unixSocket != "" {
+		dialer := &net.Dialer{Timeout: 30 * time.Second}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", unixSocket)
+		}
+		if cfg.Proxy != "" {
+			r.logger.Println("Proxy is not used for a Unix socket connection")
+		}
+	} else {
+		proxy, err := r.proxyFunc(cfg.Proxy, cfg.NoProxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxy
+	}
+
+	tlsConfig, err := r.newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// newAuthClient creates the HTTP client for requests to the authorization server
+// The authorization server is never reached through the server's Unix socket, and the TLS
+// options only apply to the MCP server, so only the proxy settings are shared.
+func (r *Relay) newAuthClient(cfg Config, unixSocket string) (*http.Client, error) {
+	if unixSocket == "" && reflect.DeepEqual(cfg.TLS, TLSOptions{}) {
+		return r.httpClient, nil
+	}
+	cfg.TLS = TLSOptions{}
+	return r.newHTTPClient(cfg, "")
+}
+
+// proxyFunc returns the function that selects the proxy for each request
+// An explicit proxy may be an http://, https:// or socks5:// URL including credentials,
+// and hosts matching noProxy bypass it. Without one, HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// from the environment are used; noProxy then replaces NO_PROXY if set.
+// Requests to localhost and loopback addresses are never proxied.
+func (r *Relay) proxyFunc(proxy string, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	proxyConfig := httpproxy.FromEnvironment()
+
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing proxy URL: %s", err.Error())
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("Unsupported proxy scheme '%s' (must be http, https, socks5 or socks5h)", u.Scheme)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("Error parsing proxy URL '%s': missing host", u.Redacted())
+		}
+
+		proxyConfig.HTTPProxy = proxy
+		proxyConfig.HTTPSProxy = proxy
+		proxyConfig.NoProxy = noProxy
+		r.logger.Printf("Using proxy %s", u.Redacted())
+	} else if noProxy != "" {
+		proxyConfig.NoProxy = noProxy
+	}
+
+	if proxyConfig.NoProxy != "" {
+		r.logger.Printf("Bypassing proxy for %s", proxyConfig.NoProxy)
+	}
+
+	selectProxy := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return selectProxy(req.URL)
+	}, nil
+}